@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// V1AdapterSt - implements Cache on top of CacheV2
+type V1AdapterSt struct {
+	c CacheV2
+}
+
+func NewV1Adapter(c CacheV2) *V1AdapterSt {
+	return &V1AdapterSt{c: c}
+}
+
+func (a *V1AdapterSt) Get(key string) ([]byte, bool, error) {
+	return a.c.Get(context.Background(), key)
+}
+
+func (a *V1AdapterSt) GetJsonObj(key string, dst any) (bool, error) {
+	return a.c.GetJsonObj(context.Background(), key, dst)
+}
+
+func (a *V1AdapterSt) Set(key string, value []byte, expiration time.Duration) error {
+	return a.c.Set(context.Background(), key, value, expiration)
+}
+
+func (a *V1AdapterSt) SetJsonObj(key string, value any, expiration time.Duration) error {
+	return a.c.SetJsonObj(context.Background(), key, value, expiration)
+}
+
+func (a *V1AdapterSt) Del(key string) error {
+	return a.c.Del(context.Background(), key)
+}
+
+func (a *V1AdapterSt) Keys(pattern string) []string {
+	keys, _ := a.c.Keys(context.Background(), pattern)
+	if keys == nil {
+		keys = []string{}
+	}
+	return keys
+}
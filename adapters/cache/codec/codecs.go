@@ -0,0 +1,78 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	Json    Codec = jsonCodecSt{}
+	Msgpack Codec = msgpackCodecSt{}
+	Gob     Codec = gobCodecSt{}
+
+	codecs = map[byte]Codec{
+		IdJson:    Json,
+		IdMsgpack: Msgpack,
+		IdGob:     Gob,
+	}
+)
+
+type jsonCodecSt struct{}
+
+func (c jsonCodecSt) Id() byte { return IdJson }
+
+func (c jsonCodecSt) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (c jsonCodecSt) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodecSt struct{}
+
+func (c msgpackCodecSt) Id() byte { return IdMsgpack }
+
+func (c msgpackCodecSt) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c msgpackCodecSt) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+type gobCodecSt struct{}
+
+func (c gobCodecSt) Id() byte { return IdGob }
+
+func (c gobCodecSt) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	err := gob.NewEncoder(buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c gobCodecSt) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Register - registers custom codec, so values encoded by it can be decoded, must be called on init
+func Register(c Codec) {
+	codecs[c.Id()] = c
+}
@@ -0,0 +1,58 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func compress(compression byte, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		buf := &bytes.Buffer{}
+
+		w := gzip.NewWriter(buf)
+
+		_, err := w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	return nil, ErrUnknownCompression
+}
+
+func decompress(compression byte, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return io.ReadAll(r)
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+
+	return nil, ErrUnknownCompression
+}
@@ -0,0 +1,23 @@
+package codec
+
+const (
+	// header: magic byte (never a first byte of json text), codec id, compression id
+	headerMagic = 0
+	headerSize  = 3
+
+	IdJson    byte = 1
+	IdMsgpack byte = 2
+	IdGob     byte = 3
+
+	CompressionNone byte = 0
+	CompressionGzip byte = 1
+	CompressionZstd byte = 2
+
+	ErrUnknownCodec       = Err("unknown_codec")
+	ErrUnknownCompression = Err("unknown_compression")
+)
+
+var defaultOptions = OptionsSt{
+	Codec:                Json,
+	CompressionThreshold: 1024,
+}
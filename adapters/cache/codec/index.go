@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"encoding/json"
+)
+
+// St - encodes values with configured codec and compression,
+// decodes values of any registered codec by header.
+// Uncompressed json is stored without header, as it was before codecs.
+type St struct {
+	opts OptionsSt
+}
+
+func New(opts OptionsSt) *St {
+	opts.mergeWithDefaults()
+
+	return &St{
+		opts: opts,
+	}
+}
+
+func Default() *St {
+	return New(OptionsSt{})
+}
+
+func (o *St) Encode(v any) ([]byte, error) {
+	data, err := o.opts.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := CompressionNone
+
+	if o.opts.Compression != CompressionNone && len(data) >= o.opts.CompressionThreshold {
+		data, err = compress(o.opts.Compression, data)
+		if err != nil {
+			return nil, err
+		}
+		compression = o.opts.Compression
+	}
+
+	if o.opts.Codec.Id() == IdJson && compression == CompressionNone {
+		return data, nil
+	}
+
+	result := make([]byte, headerSize, headerSize+len(data))
+	result[0] = headerMagic
+	result[1] = o.opts.Codec.Id()
+	result[2] = compression
+
+	return append(result, data...), nil
+}
+
+func (o *St) Decode(data []byte, v any) error {
+	if len(data) < headerSize || data[0] != headerMagic {
+		return json.Unmarshal(data, v)
+	}
+
+	c, ok := codecs[data[1]]
+	if !ok {
+		return ErrUnknownCodec
+	}
+
+	data, err := decompress(data[2], data[headerSize:])
+	if err != nil {
+		return err
+	}
+
+	return c.Unmarshal(data, v)
+}
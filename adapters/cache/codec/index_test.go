@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testObjSt struct {
+	A string  `json:"a"`
+	B []int64 `json:"b"`
+}
+
+func TestSt(t *testing.T) {
+	obj := testObjSt{
+		A: strings.Repeat("hello", 100),
+		B: []int64{1, 2, 3},
+	}
+
+	tests := []OptionsSt{
+		{},
+		{Codec: Msgpack},
+		{Codec: Gob},
+		{Codec: Json, Compression: CompressionGzip},
+		{Codec: Msgpack, Compression: CompressionZstd},
+		{Codec: Gob, Compression: CompressionZstd, CompressionThreshold: 1 << 20},
+	}
+
+	for ttI, tt := range tests {
+		t.Run(strconv.Itoa(ttI+1), func(t *testing.T) {
+			raw, err := New(tt).Encode(obj)
+			require.Nil(t, err)
+
+			// any codec instance decodes values of other codecs
+			dst := testObjSt{}
+			err = Default().Decode(raw, &dst)
+			require.Nil(t, err)
+			require.Equal(t, obj, dst)
+		})
+	}
+}
+
+func TestStJsonWithoutHeader(t *testing.T) {
+	raw, err := Default().Encode(testObjSt{A: "a"})
+	require.Nil(t, err)
+	require.JSONEq(t, `{"a": "a", "b": null}`, string(raw))
+
+	dst := testObjSt{}
+	err = New(OptionsSt{Codec: Msgpack}).Decode([]byte(`{"a": "x"}`), &dst)
+	require.Nil(t, err)
+	require.Equal(t, "x", dst.A)
+
+	err = Default().Decode([]byte{headerMagic, 99, 0}, &dst)
+	require.Equal(t, ErrUnknownCodec, err)
+}
+
+func TestStCompressionThreshold(t *testing.T) {
+	small := testObjSt{A: "a"}
+
+	tests := []struct {
+		threshold  int
+		compressed bool
+	}{
+		{0, false},
+		{-1, true},
+		{1, true},
+	}
+
+	for _, tt := range tests {
+		raw, err := New(OptionsSt{Compression: CompressionGzip, CompressionThreshold: tt.threshold}).Encode(small)
+		require.Nil(t, err)
+
+		if tt.compressed {
+			require.Equal(t, []byte{headerMagic, IdJson, CompressionGzip}, raw[:headerSize])
+		} else {
+			require.JSONEq(t, `{"a": "a", "b": null}`, string(raw))
+		}
+
+		dst := testObjSt{}
+		err = Default().Decode(raw, &dst)
+		require.Nil(t, err)
+		require.Equal(t, small, dst)
+	}
+}
@@ -0,0 +1,33 @@
+package codec
+
+type Err string
+
+func (e Err) Error() string {
+	return string(e)
+}
+
+type Codec interface {
+	Id() byte
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Options
+
+type OptionsSt struct {
+	Codec       Codec
+	Compression byte
+	// CompressionThreshold - values smaller than this size are not compressed, default 1024, -1 - compress all values
+	CompressionThreshold int
+}
+
+func (o *OptionsSt) mergeWithDefaults() {
+	if o.Codec == nil {
+		o.Codec = defaultOptions.Codec
+	}
+	if o.CompressionThreshold == 0 {
+		o.CompressionThreshold = defaultOptions.CompressionThreshold
+	} else if o.CompressionThreshold < 0 {
+		o.CompressionThreshold = 0
+	}
+}
@@ -1,6 +1,9 @@
 package cache
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Cache interface {
 	Get(key string) ([]byte, bool, error)
@@ -10,3 +13,39 @@ type Cache interface {
 	Del(key string) error
 	Keys(pattern string) []string
 }
+
+type CacheV2 interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	GetJsonObj(ctx context.Context, key string, dst any) (bool, error)
+	Set(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	SetJsonObj(ctx context.Context, key string, value any, expiration time.Duration) error
+	Del(ctx context.Context, key string) error
+	Keys(ctx context.Context, pattern string) ([]string, error)
+
+	MGet(ctx context.Context, keys []string) (map[string][]byte, error)
+	MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error)
+	GetDel(ctx context.Context, key string) ([]byte, bool, error)
+	Incr(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	Decr(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	IncrBy(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error)
+	Expire(ctx context.Context, key string, expiration time.Duration) (bool, error)
+	// TTL - returns zero duration for keys without expiration
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+
+	SetWithTags(ctx context.Context, key string, value []byte, expiration time.Duration, tags ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+	DelByPattern(ctx context.Context, pattern string) error
+}
+
+type Locker interface {
+	// TryLock - returns token of acquired lock, ok is false if lock is held by someone else
+	TryLock(ctx context.Context, key string, ttl time.Duration) (string, bool, error)
+	Unlock(ctx context.Context, key, token string) (bool, error)
+	ExtendLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+}
+
+type RateLimiter interface {
+	// Allow - sliding window limiter, returns retry-after duration if not allowed
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error)
+}
@@ -0,0 +1,18 @@
+package rnd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Token - returns random hex token, used by cache backends for locks, rate limiter members and instance ids
+func Token() (string, error) {
+	raw := make([]byte, 16)
+
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
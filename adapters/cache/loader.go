@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rendau/dop/dopErrs"
+	"github.com/rendau/dop/dopTools"
+	"golang.org/x/sync/singleflight"
+)
+
+var loadGroup singleflight.Group
+
+type LoadOptionsSt struct {
+	// TTL - time while loaded value is fresh
+	TTL time.Duration
+	// NegativeTTL - if > 0, dopErrs.ObjectNotFound from loader is cached for this duration
+	NegativeTTL time.Duration
+	// StaleTTL - if > 0, value is served stale for this duration after TTL while it is reloaded in background
+	StaleTTL time.Duration
+	// LoadTimeout - timeout of loader, it is not canceled with context of caller (shared by waiters), default 30s
+	LoadTimeout time.Duration
+}
+
+const defaultLoadTimeout = 30 * time.Second
+
+type loadEnvelopeSt[T any] struct {
+	V         T     `json:"v"`
+	NotFound  bool  `json:"nf,omitempty"`
+	FreshTill int64 `json:"ft,omitempty"`
+}
+
+// GetOrLoad - returns cached value or loads it, concurrent loads of the same key are deduplicated.
+// Value is stored wrapped in envelope (with not-found and freshness marks), so the key must be
+// accessed only through GetOrLoad/GetOrLoadWithOptions, not with GetJsonObj/SetJsonObj
+func GetOrLoad[T any](ctx context.Context, c CacheV2, key string, ttl time.Duration, loader func(context.Context) (T, error)) (T, error) {
+	return GetOrLoadWithOptions(ctx, c, key, loader, LoadOptionsSt{TTL: ttl})
+}
+
+// GetOrLoadWithOptions - GetOrLoad with negative caching, stale-while-revalidate and load timeout
+func GetOrLoadWithOptions[T any](ctx context.Context, c CacheV2, key string, loader func(context.Context) (T, error), opts LoadOptionsSt) (T, error) {
+	var zero T
+
+	envelope := loadEnvelopeSt[T]{}
+
+	found, err := c.GetJsonObj(ctx, key, &envelope)
+	if err == nil && found {
+		if envelope.NotFound {
+			return zero, dopErrs.ObjectNotFound
+		}
+
+		if envelope.FreshTill > 0 && time.Now().UnixMilli() > envelope.FreshTill {
+			// stale, reload in background
+			loadGroup.DoChan(loadGroupKey[T](c, key), func() (any, error) {
+				return loadDetached(ctx, c, key, loader, opts)
+			})
+		}
+
+		return envelope.V, nil
+	}
+
+	ch := loadGroup.DoChan(loadGroupKey[T](c, key), func() (any, error) {
+		return loadDetached(ctx, c, key, loader, opts)
+	})
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return zero, r.Err
+		}
+
+		if r.Val == nil { // nil of interface type
+			return zero, nil
+		}
+
+		res, ok := r.Val.(T)
+		if !ok {
+			return zero, fmt.Errorf("cache: loaded value of key %q has type %T, expected %T", key, r.Val, zero)
+		}
+
+		return res, nil
+	}
+}
+
+// loadDetached - loads with values of ctx, but without its cancellation
+func loadDetached[T any](ctx context.Context, c CacheV2, key string, loader func(context.Context) (T, error), opts LoadOptionsSt) (T, error) {
+	timeout := opts.LoadTimeout
+	if timeout <= 0 {
+		timeout = defaultLoadTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(dopTools.DetachedContext(ctx), timeout)
+	defer cancel()
+
+	return load(ctx, c, key, loader, opts)
+}
+
+func load[T any](ctx context.Context, c CacheV2, key string, loader func(context.Context) (T, error), opts LoadOptionsSt) (T, error) {
+	v, err := loader(ctx)
+	if err != nil {
+		if opts.NegativeTTL > 0 && errors.Is(err, dopErrs.ObjectNotFound) {
+			_ = c.SetJsonObj(ctx, key, loadEnvelopeSt[T]{NotFound: true}, opts.NegativeTTL)
+		}
+		return v, err
+	}
+
+	envelope := loadEnvelopeSt[T]{V: v}
+	expiration := opts.TTL
+
+	if opts.StaleTTL > 0 && opts.TTL > 0 {
+		envelope.FreshTill = time.Now().Add(opts.TTL).UnixMilli()
+		expiration += opts.StaleTTL
+	}
+
+	_ = c.SetJsonObj(ctx, key, envelope, expiration)
+
+	return v, nil
+}
+
+// loadGroupKey - type is a part of key, so loaders of different types are not shared
+func loadGroupKey[T any](c CacheV2, key string) string {
+	var zero T
+
+	return fmt.Sprintf("%p:%T:%s", c, &zero, key)
+}
@@ -0,0 +1,185 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache"
+	"github.com/rendau/dop/adapters/cache/mem"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+
+	c := mem.New()
+	defer c.Close()
+
+	var loadCnt int32
+
+	loader := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loadCnt, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+
+	type resultSt struct {
+		v   string
+		err error
+	}
+
+	results := make(chan resultSt, 10)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(ctx, c, "k", time.Minute, loader)
+			results <- resultSt{v, err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		require.Nil(t, r.err)
+		require.Equal(t, "value", r.v)
+	}
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&loadCnt))
+
+	v, err := cache.GetOrLoad(ctx, c, "k", time.Minute, loader)
+	require.Nil(t, err)
+	require.Equal(t, "value", v)
+	require.Equal(t, int32(1), atomic.LoadInt32(&loadCnt))
+}
+
+func TestGetOrLoadCanceledWaiter(t *testing.T) {
+	c := mem.New()
+	defer c.Close()
+
+	started := make(chan struct{})
+
+	loader := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return "value", nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.GetOrLoad(ctx, c, "k", time.Minute, loader)
+		firstErr <- err
+	}()
+
+	<-started
+
+	secondRes := make(chan string, 1)
+	secondErr := make(chan error, 1)
+	go func() {
+		v, err := cache.GetOrLoad(context.Background(), c, "k", time.Minute, loader)
+		secondRes <- v
+		secondErr <- err
+	}()
+
+	// first caller gives up, load continues for second one
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+
+	require.Nil(t, <-secondErr)
+	require.Equal(t, "value", <-secondRes)
+}
+
+func TestGetOrLoadTypes(t *testing.T) {
+	ctx := context.Background()
+
+	c := mem.New()
+	defer c.Close()
+
+	loadedStr := make(chan struct{})
+
+	go func() {
+		_, _ = cache.GetOrLoad(ctx, c, "k", time.Minute, func(ctx context.Context) (string, error) {
+			close(loadedStr)
+			time.Sleep(30 * time.Millisecond)
+			return "value", nil
+		})
+	}()
+
+	<-loadedStr
+
+	// same key with other type is not shared with string loader
+	v, err := cache.GetOrLoad(ctx, c, "k", time.Minute, func(ctx context.Context) (int, error) {
+		return 8, nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, 8, v)
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	ctx := context.Background()
+
+	c := mem.New()
+	defer c.Close()
+
+	var loadCnt int32
+
+	loader := func(ctx context.Context) (*int, error) {
+		atomic.AddInt32(&loadCnt, 1)
+		return nil, dopErrs.ObjectNotFound
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetOrLoadWithOptions(ctx, c, "k", loader, cache.LoadOptionsSt{
+			TTL:         time.Minute,
+			NegativeTTL: time.Minute,
+		})
+		require.ErrorIs(t, err, dopErrs.ObjectNotFound)
+	}
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&loadCnt))
+}
+
+func TestGetOrLoadStale(t *testing.T) {
+	ctx := context.Background()
+
+	c := mem.New()
+	defer c.Close()
+
+	var loadCnt int32
+
+	loader := func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&loadCnt, 1), nil
+	}
+
+	opts := cache.LoadOptionsSt{
+		TTL:      10 * time.Millisecond,
+		StaleTTL: time.Minute,
+	}
+
+	v, err := cache.GetOrLoadWithOptions(ctx, c, "k", loader, opts)
+	require.Nil(t, err)
+	require.Equal(t, int32(1), v)
+
+	time.Sleep(20 * time.Millisecond)
+
+	v, err = cache.GetOrLoadWithOptions(ctx, c, "k", loader, opts)
+	require.Nil(t, err)
+	require.Equal(t, int32(1), v) // stale value
+
+	require.Eventually(t, func() bool {
+		v, err = cache.GetOrLoadWithOptions(ctx, c, "k", loader, opts)
+		return err == nil && v >= 2
+	}, time.Second, 5*time.Millisecond)
+}
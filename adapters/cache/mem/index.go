@@ -1,42 +1,155 @@
+// Package mem - in-memory cache.CacheV2, wrap it with cache.NewV1Adapter where cache.Cache (without context) is required
 package mem
 
 import (
-	"encoding/json"
+	"container/list"
+	"context"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/rendau/dop/adapters/cache"
+	"github.com/rendau/dop/adapters/cache/internal/rnd"
+	"github.com/rendau/dop/adapters/cache/metrics"
 )
 
 type St struct {
-	data map[string][]byte
-	mu   sync.RWMutex
+	opts OptionsSt
+
+	data  map[string]*itemSt
+	tags  map[string]map[string]struct{}
+	locks map[string]*lockSt
+	rates map[string]*rateWindowSt
+	lru   *list.List
+	bytes int64
+	stats StatsSt
+	mu    sync.Mutex
+
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+	closeOnce sync.Once
 }
 
+var (
+	_ cache.CacheV2     = (*St)(nil)
+	_ cache.Locker      = (*St)(nil)
+	_ cache.RateLimiter = (*St)(nil)
+)
+
 func New() *St {
-	return &St{
-		data: map[string][]byte{},
+	return NewWithOptions(OptionsSt{})
+}
+
+func NewWithOptions(opts OptionsSt) *St {
+	opts.mergeWithDefaults()
+
+	c := &St{
+		opts:     opts,
+		data:     map[string]*itemSt{},
+		tags:     map[string]map[string]struct{}{},
+		locks:    map[string]*lockSt{},
+		rates:    map[string]*rateWindowSt{},
+		lru:      list.New(),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
 	}
+
+	if opts.CleanupInterval > 0 {
+		go c.cleaner()
+	} else {
+		close(c.doneChan)
+	}
+
+	return c
 }
 
-func (c *St) Get(key string) ([]byte, bool, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *St) cleaner() {
+	defer close(c.doneChan)
 
-	data, ok := c.data[key]
-	if !ok {
+	ticker := time.NewTicker(c.opts.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.DeleteExpired()
+		}
+	}
+}
+
+// Close - stops background eviction, cache stays usable
+func (c *St) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stopChan)
+	})
+	<-c.doneChan
+}
+
+func (c *St) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	for _, item := range c.data {
+		if item.expired(now) {
+			c.removeItem(item)
+			c.stats.Expired++
+		}
+	}
+
+	for k, l := range c.locks {
+		if !now.Before(l.expiresAt) {
+			delete(c.locks, k)
+		}
+	}
+
+	for k, rw := range c.rates {
+		rw.trim(now)
+		if len(rw.hits) == 0 {
+			delete(c.rates, k)
+		}
+	}
+}
+
+func (c *St) Stats() StatsSt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := c.stats
+	res.Entries = len(c.data)
+	res.Bytes = c.bytes
+
+	return res
+}
+
+func (c *St) Get(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("get", key, time.Now(), &ok, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.getItem(key, time.Now())
+	if item == nil {
+		c.stats.Misses++
 		return nil, false, nil
 	}
 
-	return data, true, nil
+	c.stats.Hits++
+
+	return item.value, true, nil
 }
 
-func (c *St) GetJsonObj(key string, dst any) (bool, error) {
-	dataRaw, ok, err := c.Get(key)
+func (c *St) GetJsonObj(ctx context.Context, key string, dst any) (bool, error) {
+	dataRaw, ok, err := c.Get(ctx, key)
 	if err != nil || !ok {
 		return ok, err
 	}
 
-	err = json.Unmarshal(dataRaw, dst)
+	err = c.opts.Codec.Decode(dataRaw, dst)
 	if err != nil {
 		return false, err
 	}
@@ -44,52 +157,466 @@ func (c *St) GetJsonObj(key string, dst any) (bool, error) {
 	return true, nil
 }
 
-func (c *St) Set(key string, value []byte, expiration time.Duration) error {
+func (c *St) Set(ctx context.Context, key string, value []byte, expiration time.Duration) (err error) {
+	defer c.observe("set", key, time.Now(), nil, &err)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data[key] = value
+	c.setItem(key, value, expiration, time.Now())
 
 	return nil
 }
 
-func (c *St) SetJsonObj(key string, value any, expiration time.Duration) error {
-	dataRaw, err := json.Marshal(value)
+func (c *St) SetJsonObj(ctx context.Context, key string, value any, expiration time.Duration) error {
+	dataRaw, err := c.opts.Codec.Encode(value)
 	if err != nil {
 		return err
 	}
 
-	return c.Set(key, dataRaw, expiration)
+	return c.Set(ctx, key, dataRaw, expiration)
 }
 
-func (c *St) Del(key string) error {
+func (c *St) Del(ctx context.Context, key string) (err error) {
+	defer c.observe("del", key, time.Now(), nil, &err)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.data, key)
+	if item, ok := c.data[key]; ok {
+		c.removeItem(item)
+	}
 
 	return nil
 }
 
-func (c *St) Keys(pattern string) []string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *St) Keys(ctx context.Context, pattern string) (keys []string, err error) {
+	defer c.observe("keys", pattern, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	var ok bool
 
+	now := time.Now()
+
 	resKeys := make([]string, 0, len(c.data))
-	for k, _ := range c.data {
+	for k, item := range c.data {
+		if item.expired(now) {
+			continue
+		}
 		if ok, _ = filepath.Match(pattern, k); ok {
 			resKeys = append(resKeys, k)
 		}
 	}
 
-	return resKeys
+	return resKeys, nil
+}
+
+func (c *St) MGet(ctx context.Context, keys []string) (result map[string][]byte, err error) {
+	now := time.Now()
+
+	defer func() {
+		// hit only if all keys found
+		hit := len(result) == len(keys)
+		c.observe("mget", firstKey(keys), now, &hit, &err)
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result = make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if item := c.getItem(key, now); item != nil {
+			result[key] = item.value
+			c.stats.Hits++
+		} else {
+			c.stats.Misses++
+		}
+	}
+
+	return result, nil
+}
+
+func (c *St) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) (err error) {
+	defer c.observe("mset", "", time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	for k, v := range values {
+		c.setItem(k, v, expiration, now)
+	}
+
+	return nil
+}
+
+func (c *St) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("setnx", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if c.getItem(key, now) != nil {
+		return false, nil
+	}
+
+	c.setItem(key, value, expiration, now)
+
+	return true, nil
+}
+
+func (c *St) GetDel(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("getdel", key, time.Now(), &ok, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.getItem(key, time.Now())
+	if item == nil {
+		c.stats.Misses++
+		return nil, false, nil
+	}
+
+	c.stats.Hits++
+
+	c.removeItem(item)
+
+	return item.value, true, nil
+}
+
+func (c *St) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, 1, expiration)
+}
+
+func (c *St) Decr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, -1, expiration)
+}
+
+func (c *St) IncrBy(ctx context.Context, key string, delta int64, expiration time.Duration) (v int64, err error) {
+	defer c.observe("incrby", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	item := c.getItem(key, now)
+	if item == nil {
+		c.setItem(key, []byte(strconv.FormatInt(delta, 10)), expiration, now)
+		return delta, nil
+	}
+
+	if len(item.value) > 0 {
+		v, err = strconv.ParseInt(string(item.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	v += delta
+
+	c.bytes -= item.size()
+	item.value = []byte(strconv.FormatInt(v, 10))
+	c.bytes += item.size()
+
+	if item.expiresAt.IsZero() && expiration > 0 {
+		item.expiresAt = now.Add(expiration)
+	}
+
+	c.evict()
+
+	return v, nil
+}
+
+func (c *St) Expire(ctx context.Context, key string, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("expire", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	item := c.getItem(key, now)
+	if item == nil {
+		return false, nil
+	}
+
+	if expiration > 0 {
+		item.expiresAt = now.Add(expiration)
+	} else {
+		item.expiresAt = time.Time{}
+	}
+
+	return true, nil
+}
+
+func (c *St) TTL(ctx context.Context, key string) (ttl time.Duration, ok bool, err error) {
+	defer c.observe("ttl", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	item := c.getItem(key, now)
+	if item == nil {
+		return 0, false, nil
+	}
+
+	if item.expiresAt.IsZero() {
+		return 0, true, nil
+	}
+
+	return item.expiresAt.Sub(now), true, nil
+}
+
+func (c *St) SetWithTags(ctx context.Context, key string, value []byte, expiration time.Duration, tags ...string) (err error) {
+	defer c.observe("setwithtags", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setItem(key, value, expiration, time.Now(), tags...)
+
+	return nil
+}
+
+func (c *St) InvalidateTags(ctx context.Context, tags ...string) (err error) {
+	defer c.observe("invalidatetags", "", time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if item, ok := c.data[key]; ok {
+				c.removeItem(item)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *St) DelByPattern(ctx context.Context, pattern string) (err error) {
+	defer c.observe("delbypattern", pattern, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ok bool
+
+	for k, item := range c.data {
+		if ok, err = filepath.Match(pattern, k); err != nil {
+			return err
+		}
+		if ok {
+			c.removeItem(item)
+		}
+	}
+
+	return nil
+}
+
+func (c *St) TryLock(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error) {
+	defer c.observe("trylock", key, time.Now(), nil, &err)
+
+	token, err = rnd.Token()
+	if err != nil {
+		return "", false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if l := c.locks[key]; l != nil && now.Before(l.expiresAt) {
+		return "", false, nil
+	}
+
+	c.locks[key] = &lockSt{
+		token:     token,
+		expiresAt: now.Add(ttl),
+	}
+
+	return token, true, nil
+}
+
+func (c *St) Unlock(ctx context.Context, key, token string) (ok bool, err error) {
+	defer c.observe("unlock", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.locks[key]
+	if l == nil || l.token != token || !time.Now().Before(l.expiresAt) {
+		return false, nil
+	}
+
+	delete(c.locks, key)
+
+	return true, nil
+}
+
+func (c *St) ExtendLock(ctx context.Context, key, token string, ttl time.Duration) (ok bool, err error) {
+	defer c.observe("extendlock", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	l := c.locks[key]
+	if l == nil || l.token != token || !now.Before(l.expiresAt) {
+		return false, nil
+	}
+
+	l.expiresAt = now.Add(ttl)
+
+	return true, nil
+}
+
+func (c *St) Allow(ctx context.Context, key string, limit int64, window time.Duration) (ok bool, retryAfter time.Duration, err error) {
+	defer c.observe("allow", key, time.Now(), nil, &err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	rw := c.rates[key]
+	if rw == nil {
+		rw = &rateWindowSt{}
+		c.rates[key] = rw
+	}
+
+	rw.window = window
+	rw.trim(now)
+
+	if int64(len(rw.hits)) < limit {
+		rw.hits = append(rw.hits, now)
+		return true, 0, nil
+	}
+
+	if len(rw.hits) == 0 {
+		return false, window, nil
+	}
+
+	return false, rw.hits[0].Add(window).Sub(now), nil
+}
+
+// observe - reports operation to hook, hit and err are read at the moment of call (use with defer)
+func (c *St) observe(op, key string, start time.Time, hit *bool, err *error) {
+	if c.opts.Hook == nil {
+		return
+	}
+
+	e := metrics.EventSt{
+		Backend:   metricsBackend,
+		Op:        op,
+		KeyPrefix: metrics.KeyPrefix(key),
+		Latency:   time.Since(start),
+		Err:       *err,
+	}
+
+	if hit != nil {
+		e.Lookup = true
+		e.Hit = *hit
+	}
+
+	c.opts.Hook.Observe(e)
 }
 
 func (c *St) Clean() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data = map[string][]byte{}
+	c.data = map[string]*itemSt{}
+	c.tags = map[string]map[string]struct{}{}
+	c.locks = map[string]*lockSt{}
+	c.rates = map[string]*rateWindowSt{}
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// getItem - must be called under lock, expired items are removed
+func (c *St) getItem(key string, now time.Time) *itemSt {
+	item, ok := c.data[key]
+	if !ok {
+		return nil
+	}
+
+	if item.expired(now) {
+		c.removeItem(item)
+		c.stats.Expired++
+		return nil
+	}
+
+	c.lru.MoveToFront(item.el)
+
+	return item
+}
+
+// setItem - must be called under lock
+func (c *St) setItem(key string, value []byte, expiration time.Duration, now time.Time, tags ...string) {
+	if item, ok := c.data[key]; ok {
+		c.removeItem(item)
+	}
+
+	item := &itemSt{key: key, value: value, tags: tags}
+
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = map[string]struct{}{}
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+
+	if expiration > 0 {
+		item.expiresAt = now.Add(expiration)
+	}
+
+	item.el = c.lru.PushFront(item)
+	c.data[key] = item
+	c.bytes += item.size()
+
+	c.evict()
+}
+
+// removeItem - must be called under lock
+func (c *St) removeItem(item *itemSt) {
+	c.lru.Remove(item.el)
+	delete(c.data, item.key)
+	c.bytes -= item.size()
+
+	for _, tag := range item.tags {
+		delete(c.tags[tag], item.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// evict - removes least recently used items while limits exceeded, must be called under lock
+func (c *St) evict() {
+	for c.lru.Len() > 0 &&
+		((c.opts.MaxEntries > 0 && len(c.data) > c.opts.MaxEntries) ||
+			(c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes)) {
+		c.removeItem(c.lru.Back().Value.(*itemSt))
+		c.stats.Evictions++
+	}
+}
+
+func firstKey(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
 }
@@ -0,0 +1,315 @@
+package mem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSt(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+
+	err := c.Set(ctx, "k1", []byte("v1"), 0)
+	require.Nil(t, err)
+
+	err = c.Set(ctx, "k2", []byte("v2"), 20*time.Millisecond)
+	require.Nil(t, err)
+
+	values, err := c.MGet(ctx, []string{"k1", "k2", "k3"})
+	require.Nil(t, err)
+	require.Equal(t, map[string][]byte{"k1": []byte("v1"), "k2": []byte("v2")}, values)
+
+	ttl, ok, err := c.TTL(ctx, "k1")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Zero(t, ttl)
+
+	ttl, ok, err = c.TTL(ctx, "k2")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Greater(t, ttl, time.Duration(0))
+
+	ok, err = c.SetNX(ctx, "k1", []byte("v11"), 0)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, ok, err = c.Get(ctx, "k2")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = c.SetNX(ctx, "k2", []byte("v22"), 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	v, ok, err := c.GetDel(ctx, "k2")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("v22"), v)
+
+	_, ok, err = c.Get(ctx, "k2")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	n, err := c.Incr(ctx, "cnt", time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = c.IncrBy(ctx, "cnt", 5, 0)
+	require.Nil(t, err)
+	require.Equal(t, int64(6), n)
+
+	n, err = c.Decr(ctx, "cnt", 0)
+	require.Nil(t, err)
+	require.Equal(t, int64(5), n)
+
+	ok, err = c.Expire(ctx, "cnt", 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ttl, _, err = c.TTL(ctx, "cnt")
+	require.Nil(t, err)
+	require.Zero(t, ttl)
+
+	ok, err = c.Expire(ctx, "missing", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func TestStLimits(t *testing.T) {
+	ctx := context.Background()
+
+	c := NewWithOptions(OptionsSt{
+		CleanupInterval: 10 * time.Millisecond,
+		MaxEntries:      2,
+		MaxBytes:        100,
+	})
+	defer c.Close()
+
+	require.Nil(t, c.Set(ctx, "k1", []byte("v1"), 0))
+	require.Nil(t, c.Set(ctx, "k2", []byte("v2"), 0))
+
+	_, ok, _ := c.Get(ctx, "k1") // k2 becomes least recently used
+	require.True(t, ok)
+
+	require.Nil(t, c.Set(ctx, "k3", []byte("v3"), 0))
+
+	_, ok, _ = c.Get(ctx, "k2")
+	require.False(t, ok)
+
+	require.Nil(t, c.Set(ctx, "big", make([]byte, 97), 0))
+
+	stats := c.Stats()
+	require.Equal(t, 1, stats.Entries)
+	require.Equal(t, int64(100), stats.Bytes)
+	require.Equal(t, uint64(3), stats.Evictions)
+	require.Equal(t, uint64(1), stats.Hits)
+	require.Equal(t, uint64(1), stats.Misses)
+
+	require.Nil(t, c.Set(ctx, "exp", []byte("v"), 5*time.Millisecond))
+
+	require.Eventually(t, func() bool {
+		return c.Stats().Expired == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestStClose(t *testing.T) {
+	c := NewWithOptions(OptionsSt{CleanupInterval: time.Millisecond})
+
+	c.Close()
+	c.Close()
+
+	select {
+	case <-c.doneChan:
+	default:
+		t.Fatal("cleaner is still running")
+	}
+}
+
+func TestStLazy(t *testing.T) {
+	ctx := context.Background()
+
+	// no cleaner by default, so Close is not required
+	c := New()
+
+	select {
+	case <-c.doneChan:
+	default:
+		t.Fatal("cleaner is running")
+	}
+
+	require.Nil(t, c.Set(ctx, "k", []byte("v"), 5*time.Millisecond))
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, ok, err := c.Get(ctx, "k")
+	require.Nil(t, err)
+	require.False(t, ok)
+	require.Equal(t, uint64(1), c.Stats().Expired)
+}
+
+func TestStTags(t *testing.T) {
+	ctx := context.Background()
+
+	c := New()
+	defer c.Close()
+
+	require.Nil(t, c.SetWithTags(ctx, "usr:1", []byte("1"), 0, "usr", "usr:1"))
+	require.Nil(t, c.SetWithTags(ctx, "usr:2", []byte("2"), 0, "usr"))
+	require.Nil(t, c.SetWithTags(ctx, "cfg", []byte("c"), 0, "cfg"))
+
+	require.Nil(t, c.InvalidateTags(ctx, "usr:1"))
+
+	keys, err := c.Keys(ctx, "*")
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"usr:2", "cfg"}, keys)
+
+	require.Nil(t, c.InvalidateTags(ctx, "usr"))
+
+	keys, err = c.Keys(ctx, "*")
+	require.Nil(t, err)
+	require.Equal(t, []string{"cfg"}, keys)
+
+	require.Nil(t, c.Set(ctx, "a:1", []byte("1"), 0))
+	require.Nil(t, c.Set(ctx, "a:2", []byte("2"), 0))
+
+	require.Nil(t, c.DelByPattern(ctx, "a:*"))
+
+	keys, err = c.Keys(ctx, "*")
+	require.Nil(t, err)
+	require.Equal(t, []string{"cfg"}, keys)
+	require.Empty(t, c.tags["usr"])
+
+	// plain set drops previous tags of key
+	require.Nil(t, c.Set(ctx, "cfg", []byte("c2"), 0))
+	require.Nil(t, c.InvalidateTags(ctx, "cfg"))
+
+	keys, err = c.Keys(ctx, "*")
+	require.Nil(t, err)
+	require.Equal(t, []string{"cfg"}, keys)
+}
+
+func TestStLock(t *testing.T) {
+	ctx := context.Background()
+
+	c := New()
+	defer c.Close()
+
+	token, ok, err := c.TryLock(ctx, "job", 20*time.Millisecond)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, ok, err = c.TryLock(ctx, "job", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = c.Unlock(ctx, "job", "bad-token")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = c.ExtendLock(ctx, "job", token, time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, ok, err = c.TryLock(ctx, "job", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// held lock is not reachable by user keys
+	require.Nil(t, c.Set(ctx, "lock:job", []byte("1"), 0))
+	require.Nil(t, c.DelByPattern(ctx, "*"))
+
+	_, ok, err = c.TryLock(ctx, "job", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = c.Unlock(ctx, "job", token)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, ok, err = c.TryLock(ctx, "job", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestStAllow(t *testing.T) {
+	ctx := context.Background()
+
+	c := New()
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := c.Allow(ctx, "otp:7000", 2, 30*time.Millisecond)
+		require.Nil(t, err)
+		require.True(t, ok)
+	}
+
+	ok, retryAfter, err := c.Allow(ctx, "otp:7000", 2, 30*time.Millisecond)
+	require.Nil(t, err)
+	require.False(t, ok)
+	require.Greater(t, retryAfter, time.Duration(0))
+
+	ok, _, err = c.Allow(ctx, "otp:7001", 2, 30*time.Millisecond)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	time.Sleep(retryAfter + 5*time.Millisecond)
+
+	ok, _, err = c.Allow(ctx, "otp:7000", 2, 30*time.Millisecond)
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestStHook(t *testing.T) {
+	ctx := context.Background()
+
+	events := make([]metrics.EventSt, 0)
+
+	c := NewWithOptions(OptionsSt{
+		Hook: metrics.HookFunc(func(e metrics.EventSt) {
+			events = append(events, e)
+		}),
+	})
+	defer c.Close()
+
+	err := c.Set(ctx, "user:1", []byte("v"), 0)
+	require.Nil(t, err)
+
+	_, ok, err := c.Get(ctx, "user:1")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, ok, err = c.Get(ctx, "user:2")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	_, err = c.MGet(ctx, []string{"user:1", "user:2"})
+	require.Nil(t, err)
+
+	err = c.DelByPattern(ctx, "[")
+	require.NotNil(t, err)
+
+	require.Len(t, events, 5)
+
+	for _, e := range events {
+		require.Equal(t, "mem", e.Backend)
+	}
+
+	require.Equal(t, "set", events[0].Op)
+	require.Equal(t, "user", events[0].KeyPrefix)
+	require.Equal(t, metrics.ResultOk, events[0].Result())
+	require.Equal(t, "get", events[1].Op)
+	require.Equal(t, metrics.ResultHit, events[1].Result())
+	require.Equal(t, metrics.ResultMiss, events[2].Result())
+	require.Equal(t, "mget", events[3].Op)
+	require.Equal(t, metrics.ResultMiss, events[3].Result())
+	require.Equal(t, "delbypattern", events[4].Op)
+	require.Equal(t, metrics.ResultError, events[4].Result())
+}
@@ -0,0 +1,76 @@
+package mem
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache/codec"
+	"github.com/rendau/dop/adapters/cache/metrics"
+)
+
+// Options
+
+type OptionsSt struct {
+	// CleanupInterval - period of background eviction of expired items (cleaner is stopped by Close),
+	// 0 - disabled, expired items are removed on access or by DeleteExpired
+	CleanupInterval time.Duration
+	// MaxEntries - max count of items, 0 - unlimited
+	MaxEntries int
+	// MaxBytes - max summary size of keys and values, 0 - unlimited
+	MaxBytes int64
+	// Codec - used by GetJsonObj/SetJsonObj, json by default
+	Codec *codec.St
+	// Hook - receives every operation, nil - disabled
+	Hook metrics.Hook
+}
+
+func (o *OptionsSt) mergeWithDefaults() {
+	if o.Codec == nil {
+		o.Codec = codec.Default()
+	}
+}
+
+// Stats
+
+type StatsSt struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Expired   uint64
+	Entries   int
+	Bytes     int64
+}
+
+type itemSt struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+	el        *list.Element
+}
+
+func (o *itemSt) expired(now time.Time) bool {
+	return !o.expiresAt.IsZero() && !now.Before(o.expiresAt)
+}
+
+func (o *itemSt) size() int64 {
+	return int64(len(o.key) + len(o.value))
+}
+
+type lockSt struct {
+	token     string
+	expiresAt time.Time
+}
+
+type rateWindowSt struct {
+	window time.Duration
+	hits   []time.Time
+}
+
+func (o *rateWindowSt) trim(now time.Time) {
+	i := 0
+	for i < len(o.hits) && !now.Before(o.hits[i].Add(o.window)) {
+		i++
+	}
+	o.hits = o.hits[i:]
+}
@@ -0,0 +1,15 @@
+package metrics
+
+const (
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+	ResultOk    = "ok"
+	ResultError = "error"
+
+	// KeyPrefixSeparator - key prefix is the part of key before first separator
+	KeyPrefixSeparator = ":"
+)
+
+var defaultPrometheusOptions = PrometheusOptionsSt{
+	Buckets: []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+}
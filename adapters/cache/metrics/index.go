@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strings"
+)
+
+// KeyPrefix - returns part of key before first KeyPrefixSeparator, empty string if there is no separator
+func KeyPrefix(key string) string {
+	if i := strings.Index(key, KeyPrefixSeparator); i >= 0 {
+		return key[:i]
+	}
+
+	return ""
+}
+
+type hooksSt []Hook
+
+// Hooks - combines several hooks into one
+func Hooks(hooks ...Hook) Hook {
+	return hooksSt(hooks)
+}
+
+func (h hooksSt) Observe(e EventSt) {
+	for _, hook := range h {
+		hook.Observe(e)
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	require.Equal(t, "user", KeyPrefix("user:1"))
+	require.Equal(t, "user", KeyPrefix("user:1:profile"))
+	require.Equal(t, "", KeyPrefix("user"))
+	require.Equal(t, "", KeyPrefix(""))
+}
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus(PrometheusOptionsSt{
+		Namespace: "app",
+		Buckets:   []float64{0.01, 0.001},
+	})
+
+	h := Hooks(p, HookFunc(func(e EventSt) {}))
+
+	h.Observe(EventSt{Backend: "mem", Op: "get", KeyPrefix: "user", Lookup: true, Hit: true, Latency: 500 * time.Microsecond})
+	h.Observe(EventSt{Backend: "mem", Op: "get", KeyPrefix: "user", Lookup: true, Hit: true, Latency: 5 * time.Millisecond})
+	h.Observe(EventSt{Backend: "mem", Op: "get", KeyPrefix: "user", Lookup: true, Latency: time.Second})
+	h.Observe(EventSt{Backend: "redis", Op: "set", KeyPrefix: `a"b`, Err: errors.New("fail")})
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	require.Equal(t, 200, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	require.Equal(t, `# HELP app_cache_operations_total Count of cache operations by result.
+# TYPE app_cache_operations_total counter
+app_cache_operations_total{backend="mem",op="get",prefix="user",result="hit"} 2
+app_cache_operations_total{backend="mem",op="get",prefix="user",result="miss"} 1
+app_cache_operations_total{backend="redis",op="set",prefix="a\"b",result="error"} 1
+# HELP app_cache_operation_duration_seconds Latency of cache operations.
+# TYPE app_cache_operation_duration_seconds histogram
+app_cache_operation_duration_seconds_bucket{backend="mem",op="get",le="0.001"} 1
+app_cache_operation_duration_seconds_bucket{backend="mem",op="get",le="0.01"} 2
+app_cache_operation_duration_seconds_bucket{backend="mem",op="get",le="+Inf"} 3
+app_cache_operation_duration_seconds_sum{backend="mem",op="get"} 1.0055
+app_cache_operation_duration_seconds_count{backend="mem",op="get"} 3
+app_cache_operation_duration_seconds_bucket{backend="redis",op="set",le="0.001"} 1
+app_cache_operation_duration_seconds_bucket{backend="redis",op="set",le="0.01"} 1
+app_cache_operation_duration_seconds_bucket{backend="redis",op="set",le="+Inf"} 1
+app_cache_operation_duration_seconds_sum{backend="redis",op="set"} 0
+app_cache_operation_duration_seconds_count{backend="redis",op="set"} 1
+`, rec.Body.String())
+}
@@ -0,0 +1,6 @@
+package metrics
+
+type Hook interface {
+	// Observe - called after every cache operation, must be safe for concurrent use and fast
+	Observe(e EventSt)
+}
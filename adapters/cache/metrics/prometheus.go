@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PrometheusSt - hook collecting operation counters and latency histograms,
+// serves them in prometheus text exposition format (mount it as http handler, e.g. on "/metrics")
+type PrometheusSt struct {
+	opts PrometheusOptionsSt
+
+	counters   map[counterKeySt]uint64
+	histograms map[histogramKeySt]*histogramSt
+	mu         sync.Mutex
+}
+
+func NewPrometheus(opts PrometheusOptionsSt) *PrometheusSt {
+	opts.mergeWithDefaults()
+
+	buckets := make([]float64, len(opts.Buckets))
+	copy(buckets, opts.Buckets)
+	sort.Float64s(buckets)
+	opts.Buckets = buckets
+
+	return &PrometheusSt{
+		opts:       opts,
+		counters:   map[counterKeySt]uint64{},
+		histograms: map[histogramKeySt]*histogramSt{},
+	}
+}
+
+func (p *PrometheusSt) Observe(e EventSt) {
+	seconds := e.Latency.Seconds()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counters[counterKeySt{
+		backend: e.Backend,
+		op:      e.Op,
+		prefix:  e.KeyPrefix,
+		result:  e.Result(),
+	}]++
+
+	hKey := histogramKeySt{backend: e.Backend, op: e.Op}
+
+	h := p.histograms[hKey]
+	if h == nil {
+		h = &histogramSt{counts: make([]uint64, len(p.opts.Buckets))}
+		p.histograms[hKey] = h
+	}
+
+	for i, b := range p.opts.Buckets {
+		if seconds <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (p *PrometheusSt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+
+	p.write(bw)
+
+	_ = bw.Flush()
+}
+
+func (p *PrometheusSt) write(w *bufio.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name := p.metricName("cache_operations_total")
+
+	fmt.Fprintf(w, "# HELP %s Count of cache operations by result.\n", name)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+
+	cKeys := make([]counterKeySt, 0, len(p.counters))
+	for k := range p.counters {
+		cKeys = append(cKeys, k)
+	}
+	sort.Slice(cKeys, func(i, j int) bool {
+		a, b := cKeys[i], cKeys[j]
+		if a.backend != b.backend {
+			return a.backend < b.backend
+		}
+		if a.op != b.op {
+			return a.op < b.op
+		}
+		if a.prefix != b.prefix {
+			return a.prefix < b.prefix
+		}
+		return a.result < b.result
+	})
+
+	for _, k := range cKeys {
+		fmt.Fprintf(
+			w, "%s{backend=%s,op=%s,prefix=%s,result=%s} %d\n",
+			name, labelValue(k.backend), labelValue(k.op), labelValue(k.prefix), labelValue(k.result), p.counters[k],
+		)
+	}
+
+	name = p.metricName("cache_operation_duration_seconds")
+
+	fmt.Fprintf(w, "# HELP %s Latency of cache operations.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	hKeys := make([]histogramKeySt, 0, len(p.histograms))
+	for k := range p.histograms {
+		hKeys = append(hKeys, k)
+	}
+	sort.Slice(hKeys, func(i, j int) bool {
+		a, b := hKeys[i], hKeys[j]
+		if a.backend != b.backend {
+			return a.backend < b.backend
+		}
+		return a.op < b.op
+	})
+
+	for _, k := range hKeys {
+		h := p.histograms[k]
+		labels := "backend=" + labelValue(k.backend) + ",op=" + labelValue(k.op)
+
+		for i, b := range p.opts.Buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(b), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func (p *PrometheusSt) metricName(name string) string {
+	if p.opts.Namespace == "" {
+		return name
+	}
+
+	return p.opts.Namespace + "_" + name
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(v string) string {
+	return `"` + labelValueReplacer.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
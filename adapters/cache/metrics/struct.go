@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+)
+
+type EventSt struct {
+	Backend   string
+	Op        string
+	KeyPrefix string
+	// Lookup - operation reads value, Hit is meaningful only for lookups
+	Lookup  bool
+	Hit     bool
+	Latency time.Duration
+	Err     error
+}
+
+// Result - one of ResultHit, ResultMiss, ResultOk, ResultError
+func (e EventSt) Result() string {
+	switch {
+	case e.Err != nil:
+		return ResultError
+	case !e.Lookup:
+		return ResultOk
+	case e.Hit:
+		return ResultHit
+	}
+
+	return ResultMiss
+}
+
+type HookFunc func(e EventSt)
+
+func (f HookFunc) Observe(e EventSt) {
+	f(e)
+}
+
+// Prometheus
+
+type PrometheusOptionsSt struct {
+	// Namespace - prefix of metric names, "<namespace>_cache_..."
+	Namespace string
+	// Buckets - latency histogram upper bounds in seconds
+	Buckets []float64
+}
+
+func (o *PrometheusOptionsSt) mergeWithDefaults() {
+	if len(o.Buckets) == 0 {
+		o.Buckets = defaultPrometheusOptions.Buckets
+	}
+}
+
+type counterKeySt struct {
+	backend string
+	op      string
+	prefix  string
+	result  string
+}
+
+type histogramKeySt struct {
+	backend string
+	op      string
+}
+
+type histogramSt struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
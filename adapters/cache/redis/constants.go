@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"time"
+)
+
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+
+	// reservedKeyPrefix - namespace of service keys, zero byte is not expected in user keys,
+	// keys of namespace are excluded from Keys and DelByPattern
+	reservedKeyPrefix = "\x00dop:"
+	tagKeyPrefix      = reservedKeyPrefix + "tag:"
+	keyTagsKeyPrefix  = reservedKeyPrefix + "key-tags:"
+	lockKeyPrefix     = reservedKeyPrefix + "lock:"
+	rateKeyPrefix     = reservedKeyPrefix + "rate:"
+
+	metricsBackend = "redis"
+
+	subscribeChanSize = 100
+)
+
+var defaultOptions = OptionsSt{
+	Mode:        ModeSingle,
+	PingTimeout: 5 * time.Second,
+}
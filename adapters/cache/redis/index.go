@@ -1,38 +1,183 @@
+// Package redis - redis cache.CacheV2, wrap it with cache.NewV1Adapter where cache.Cache (without context) is required
 package redis
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rendau/dop/adapters/cache"
+	"github.com/rendau/dop/adapters/cache/codec"
+	"github.com/rendau/dop/adapters/cache/internal/rnd"
+	"github.com/rendau/dop/adapters/cache/metrics"
 	"github.com/rendau/dop/adapters/logger"
 )
 
+var incrByScript = redis.NewScript(`
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v
+`)
+
+// tagAddScript - adds key to tag set, tag set lives as long as its longest-living key
+var tagAddScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if existed == 0 then
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+	return 1
+end
+local cur = redis.call('PTTL', KEYS[1])
+if cur ~= -1 then
+	if ttl <= 0 then
+		redis.call('PERSIST', KEYS[1])
+	elseif cur < ttl then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+end
+return 1
+`)
+
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+var extendLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// allowScript - sliding window log, returns {allowed, retry-after-ms}
+var allowScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < limit then
+	redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] == nil then
+	return {0, window}
+end
+return {0, tonumber(oldest[2]) + window - now}
+`)
+
 type St struct {
 	lg     logger.WarnAndError
 	prefix string
+	codec  *codec.St
+	hook   metrics.Hook
 
-	r   *redis.Client
-	ctx context.Context
+	r redis.UniversalClient
 }
 
+var (
+	_ cache.CacheV2     = (*St)(nil)
+	_ cache.Locker      = (*St)(nil)
+	_ cache.RateLimiter = (*St)(nil)
+)
+
 func New(lg logger.WarnAndError, url, psw string, db int, prefix string) *St {
 	return &St{
 		lg:     lg,
 		prefix: prefix,
+		codec:  codec.Default(),
 
 		r: redis.NewClient(&redis.Options{
 			Addr:     url,
 			Password: psw,
 			DB:       db,
 		}),
-		ctx: context.Background(),
 	}
 }
 
-func (c *St) Get(key string) ([]byte, bool, error) {
-	data, err := c.r.Get(c.ctx, c.prefix+key).Bytes()
+func NewWithOptions(lg logger.WarnAndError, opts OptionsSt) (*St, error) {
+	opts.mergeWithDefaults()
+
+	uOpts := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		DB:               opts.Db,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolSize:         opts.PoolSize,
+		MinIdleConns:     opts.MinIdleConns,
+		PoolTimeout:      opts.PoolTimeout,
+		TLSConfig:        opts.TLSConfig,
+		MasterName:       opts.MasterName,
+	}
+
+	c := &St{
+		lg:     lg,
+		prefix: opts.Prefix,
+		codec:  opts.Codec,
+		hook:   opts.Hook,
+	}
+
+	switch opts.Mode {
+	case ModeSingle:
+		if len(opts.Addrs) > 1 {
+			return nil, errors.New("redis: single mode requires one address")
+		}
+		c.r = redis.NewClient(uOpts.Simple())
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, errors.New("redis: sentinel mode requires master name")
+		}
+		c.r = redis.NewFailoverClient(uOpts.Failover())
+	case ModeCluster:
+		c.r = redis.NewClusterClient(uOpts.Cluster())
+	default:
+		return nil, errors.New("redis: unknown mode: " + opts.Mode)
+	}
+
+	if !opts.SkipPing {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.PingTimeout)
+		defer cancel()
+
+		err := c.r.Ping(ctx).Err()
+		if err != nil {
+			_ = c.r.Close()
+			err = fmt.Errorf("redis: fail to ping (mode: %s, addrs: %v): %w", opts.Mode, opts.Addrs, err)
+			lg.Errorw("Redis: fail to connect", err)
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *St) Close() error {
+	return c.r.Close()
+}
+
+func (c *St) Get(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("get", key, time.Now(), &ok, &err)
+
+	data, err = c.r.Get(ctx, c.prefix+key).Bytes()
 	if err == redis.Nil {
 		return nil, false, nil
 	}
@@ -44,13 +189,13 @@ func (c *St) Get(key string) ([]byte, bool, error) {
 	return data, true, nil
 }
 
-func (c *St) GetJsonObj(key string, dst any) (bool, error) {
-	dataRaw, ok, err := c.Get(key)
+func (c *St) GetJsonObj(ctx context.Context, key string, dst any) (bool, error) {
+	dataRaw, ok, err := c.Get(ctx, key)
 	if err != nil || !ok {
 		return ok, err
 	}
 
-	err = json.Unmarshal(dataRaw, dst)
+	err = c.codec.Decode(dataRaw, dst)
 	if err != nil {
 		return false, err
 	}
@@ -58,8 +203,10 @@ func (c *St) GetJsonObj(key string, dst any) (bool, error) {
 	return true, nil
 }
 
-func (c *St) Set(key string, value []byte, expiration time.Duration) error {
-	err := c.r.Set(c.ctx, c.prefix+key, value, expiration).Err()
+func (c *St) Set(ctx context.Context, key string, value []byte, expiration time.Duration) (err error) {
+	defer c.observe("set", key, time.Now(), nil, &err)
+
+	err = c.r.Set(ctx, c.prefix+key, value, expiration).Err()
 	if err != nil {
 		c.lg.Errorw("Redis: fail to 'set'", err)
 	}
@@ -67,40 +214,497 @@ func (c *St) Set(key string, value []byte, expiration time.Duration) error {
 	return err
 }
 
-func (c *St) SetJsonObj(key string, value any, expiration time.Duration) error {
-	dataRaw, err := json.Marshal(value)
+func (c *St) SetJsonObj(ctx context.Context, key string, value any, expiration time.Duration) error {
+	dataRaw, err := c.codec.Encode(value)
 	if err != nil {
 		return err
 	}
 
-	return c.Set(key, dataRaw, expiration)
+	return c.Set(ctx, key, dataRaw, expiration)
 }
 
-func (c *St) Del(key string) error {
-	err := c.r.Del(c.ctx, c.prefix+key).Err()
-	if err != nil {
-		c.lg.Errorw("Redis: fail to 'del'", err)
+func (c *St) Del(ctx context.Context, key string) (err error) {
+	defer c.observe("del", key, time.Now(), nil, &err)
+
+	return c.delRaw(ctx, []string{c.prefix + key})
+}
+
+func (c *St) Keys(ctx context.Context, pattern string) (keys []string, err error) {
+	defer c.observe("keys", pattern, time.Now(), nil, &err)
+
+	return c.keys(ctx, pattern)
+}
+
+// keys - returns prefixed keys matching pattern
+func (c *St) keys(ctx context.Context, pattern string) ([]string, error) {
+	cc, ok := c.r.(*redis.ClusterClient)
+	if !ok {
+		return c.scan(ctx, c.r, c.prefix+pattern)
 	}
 
-	return err
+	resKeys := make([]string, 0)
+	mu := sync.Mutex{}
+
+	err := cc.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		keys, err := c.scan(ctx, client, c.prefix+pattern)
+
+		mu.Lock()
+		resKeys = append(resKeys, keys...)
+		mu.Unlock()
+
+		return err
+	})
+
+	return resKeys, err
 }
 
-func (c *St) Keys(pattern string) []string {
+func (c *St) scan(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
 	var err error
 	var cursor uint64
 	var keys []string
 
 	resKeys := make([]string, 0)
 	for {
-		keys, cursor, err = c.r.Scan(c.ctx, cursor, c.prefix+pattern, 30).Result()
+		keys, cursor, err = client.Scan(ctx, cursor, pattern, 30).Result()
 		if err != nil {
-			break
+			c.lg.Errorw("Redis: fail to 'scan'", err)
+			return resKeys, err
+		}
+		for _, k := range keys {
+			if !strings.HasPrefix(k, c.prefix+reservedKeyPrefix) {
+				resKeys = append(resKeys, k)
+			}
 		}
-		resKeys = append(resKeys, keys...)
 		if cursor == 0 {
 			break
 		}
 	}
 
-	return resKeys
+	return resKeys, nil
+}
+
+func (c *St) MGet(ctx context.Context, keys []string) (result map[string][]byte, err error) {
+	defer func(start time.Time) {
+		// hit only if all keys found
+		hit := len(result) == len(keys)
+		c.observe("mget", firstKey(keys), start, &hit, &err)
+	}(time.Now())
+
+	result = make(map[string][]byte, len(keys))
+
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	// pipeline instead of MGET, keys may belong to different cluster slots
+	cmds := make([]*redis.StringCmd, len(keys))
+
+	_, err = c.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			cmds[i] = pipe.Get(ctx, c.prefix+k)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		c.lg.Errorw("Redis: fail to 'mget'", err)
+		return nil, err
+	}
+
+	var data []byte
+
+	for i, cmd := range cmds {
+		data, err = cmd.Bytes()
+		if err == nil {
+			result[keys[i]] = data
+		}
+	}
+
+	return result, nil
+}
+
+func (c *St) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) (err error) {
+	defer c.observe("mset", "", time.Now(), nil, &err)
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err = c.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, v := range values {
+			pipe.Set(ctx, c.prefix+k, v, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'mset'", err)
+	}
+
+	return err
+}
+
+func (c *St) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("setnx", key, time.Now(), nil, &err)
+
+	ok, err = c.r.SetNX(ctx, c.prefix+key, value, expiration).Result()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'setnx'", err)
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (c *St) GetDel(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("getdel", key, time.Now(), &ok, &err)
+
+	data, err = c.r.GetDel(ctx, c.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'getdel'", err)
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+func (c *St) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, 1, expiration)
+}
+
+func (c *St) Decr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, -1, expiration)
+}
+
+func (c *St) IncrBy(ctx context.Context, key string, delta int64, expiration time.Duration) (v int64, err error) {
+	defer c.observe("incrby", key, time.Now(), nil, &err)
+
+	v, err = incrByScript.Run(ctx, c.r, []string{c.prefix + key}, delta, expiration.Milliseconds()).Int64()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'incrby'", err)
+		return 0, err
+	}
+
+	return v, nil
+}
+
+func (c *St) Expire(ctx context.Context, key string, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("expire", key, time.Now(), nil, &err)
+
+	if expiration > 0 {
+		ok, err = c.r.PExpire(ctx, c.prefix+key, expiration).Result()
+	} else {
+		ok, err = c.r.Persist(ctx, c.prefix+key).Result()
+		if err == nil && !ok { // persist returns false also for keys without expiration
+			var n int64
+			n, err = c.r.Exists(ctx, c.prefix+key).Result()
+			ok = n > 0
+		}
+	}
+	if err == nil && ok { // tags of key live as long as key
+		if expiration > 0 {
+			err = c.r.PExpire(ctx, c.keyTagsKey(key), expiration).Err()
+		} else {
+			err = c.r.Persist(ctx, c.keyTagsKey(key)).Err()
+		}
+	}
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'expire'", err)
+		return false, err
+	}
+
+	return ok, nil
+}
+
+func (c *St) TTL(ctx context.Context, key string) (ttl time.Duration, ok bool, err error) {
+	defer c.observe("ttl", key, time.Now(), nil, &err)
+
+	v, err := c.r.PTTL(ctx, c.prefix+key).Result()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'ttl'", err)
+		return 0, false, err
+	}
+
+	switch {
+	case v == -2:
+		return 0, false, nil
+	case v < 0:
+		return 0, true, nil
+	}
+
+	return v, true, nil
+}
+
+// SetWithTags - unlike mem, tags are not dropped when key is overwritten by Set/MSet or taken by GetDel
+// (it would cost an extra write on every set), so InvalidateTags may also delete such key
+func (c *St) SetWithTags(ctx context.Context, key string, value []byte, expiration time.Duration, tags ...string) (err error) {
+	defer c.observe("setwithtags", key, time.Now(), nil, &err)
+
+	// tags of key are kept beside it and replaced only here, tag sets may contain outdated keys (overwritten or expired)
+	_, err = c.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.prefix+key, value, expiration)
+		pipe.Del(ctx, c.keyTagsKey(key))
+
+		if len(tags) > 0 {
+			members := make([]any, len(tags))
+			for i, tag := range tags {
+				members[i] = tag
+			}
+
+			pipe.SAdd(ctx, c.keyTagsKey(key), members...)
+			if expiration > 0 {
+				pipe.PExpire(ctx, c.keyTagsKey(key), expiration)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'set'", err)
+		return err
+	}
+
+	for _, tag := range tags {
+		err = tagAddScript.Run(ctx, c.r, []string{c.tagKey(tag)}, key, expiration.Milliseconds()).Err()
+		if err != nil {
+			c.lg.Errorw("Redis: fail to add tag", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *St) InvalidateTags(ctx context.Context, tags ...string) error {
+	_, err := c.InvalidateTagsKeys(ctx, tags...)
+	return err
+}
+
+// InvalidateTagsKeys - deletes keys of tags, returns deleted keys (without prefix)
+func (c *St) InvalidateTagsKeys(ctx context.Context, tags ...string) (resKeys []string, err error) {
+	defer c.observe("invalidatetags", "", time.Now(), nil, &err)
+
+	resKeys = make([]string, 0)
+
+	for _, tag := range tags {
+		keys, err := c.r.SMembers(ctx, c.tagKey(tag)).Result()
+		if err != nil {
+			c.lg.Errorw("Redis: fail to 'smembers'", err)
+			return resKeys, err
+		}
+
+		keys, err = c.taggedKeys(ctx, tag, keys)
+		if err != nil {
+			return resKeys, err
+		}
+
+		pKeys := make([]string, len(keys), len(keys)+1)
+		for i, k := range keys {
+			pKeys[i] = c.prefix + k
+		}
+
+		err = c.delRaw(ctx, append(pKeys, c.tagKey(tag)))
+		if err != nil {
+			return resKeys, err
+		}
+
+		resKeys = append(resKeys, keys...)
+	}
+
+	return resKeys, nil
+}
+
+func (c *St) DelByPattern(ctx context.Context, pattern string) (err error) {
+	defer c.observe("delbypattern", pattern, time.Now(), nil, &err)
+
+	pKeys, err := c.keys(ctx, pattern)
+	if err != nil {
+		return err
+	}
+
+	return c.delRaw(ctx, pKeys)
+}
+
+// taggedKeys - filters members of tag set, which are still tagged with it
+func (c *St) taggedKeys(ctx context.Context, tag string, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return keys, nil
+	}
+
+	cmds := make([]*redis.BoolCmd, len(keys))
+
+	_, err := c.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			cmds[i] = pipe.SIsMember(ctx, c.keyTagsKey(k), tag)
+		}
+		return nil
+	})
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'sismember'", err)
+		return nil, err
+	}
+
+	result := make([]string, 0, len(keys))
+
+	for i, cmd := range cmds {
+		if cmd.Val() {
+			result = append(result, keys[i])
+		}
+	}
+
+	return result, nil
+}
+
+// delRaw - deletes already prefixed keys with their tags
+func (c *St) delRaw(ctx context.Context, pKeys []string) error {
+	if len(pKeys) == 0 {
+		return nil
+	}
+
+	// one DEL per key, keys may belong to different cluster slots
+	_, err := c.r.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range pKeys {
+			pipe.Del(ctx, k)
+			if !strings.HasPrefix(k, c.prefix+reservedKeyPrefix) {
+				pipe.Del(ctx, c.keyTagsKey(strings.TrimPrefix(k, c.prefix)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'del'", err)
+	}
+
+	return err
+}
+
+func (c *St) tagKey(tag string) string {
+	return c.prefix + tagKeyPrefix + tag
+}
+
+// keyTagsKey - key of set of tags of key
+func (c *St) keyTagsKey(key string) string {
+	return c.prefix + keyTagsKeyPrefix + key
+}
+
+func (c *St) TryLock(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error) {
+	defer c.observe("trylock", key, time.Now(), nil, &err)
+
+	token, err = rnd.Token()
+	if err != nil {
+		return "", false, err
+	}
+
+	ok, err = c.r.SetNX(ctx, c.prefix+lockKeyPrefix+key, token, ttl).Result()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to lock", err)
+		return "", false, err
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	return token, true, nil
+}
+
+func (c *St) Unlock(ctx context.Context, key, token string) (ok bool, err error) {
+	defer c.observe("unlock", key, time.Now(), nil, &err)
+
+	n, err := unlockScript.Run(ctx, c.r, []string{c.prefix + lockKeyPrefix + key}, token).Int64()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to unlock", err)
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (c *St) ExtendLock(ctx context.Context, key, token string, ttl time.Duration) (ok bool, err error) {
+	defer c.observe("extendlock", key, time.Now(), nil, &err)
+
+	n, err := extendLockScript.Run(ctx, c.r, []string{c.prefix + lockKeyPrefix + key}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to extend lock", err)
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (c *St) Allow(ctx context.Context, key string, limit int64, window time.Duration) (ok bool, retryAfter time.Duration, err error) {
+	defer c.observe("allow", key, time.Now(), nil, &err)
+
+	member, err := rnd.Token()
+	if err != nil {
+		return false, 0, err
+	}
+
+	rep, err := allowScript.Run(ctx, c.r, []string{c.prefix + rateKeyPrefix + key}, limit, window.Milliseconds(), member).Int64Slice()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to check rate limit", err)
+		return false, 0, err
+	}
+
+	if len(rep) != 2 {
+		return false, 0, errors.New("redis: bad rate limit script reply")
+	}
+
+	return rep[0] == 1, time.Duration(rep[1]) * time.Millisecond, nil
+}
+
+func (c *St) Publish(ctx context.Context, channel string, msg []byte) error {
+	err := c.r.Publish(ctx, c.prefix+channel, msg).Err()
+	if err != nil {
+		c.lg.Errorw("Redis: fail to 'publish'", err)
+	}
+
+	return err
+}
+
+// Subscribe - returns channel of message payloads, it is closed after unsubscribe
+func (c *St) Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error) {
+	pubSub := c.r.Subscribe(ctx, c.prefix+channel)
+
+	msgChan := make(chan []byte, subscribeChanSize)
+
+	go func() {
+		defer close(msgChan)
+
+		for msg := range pubSub.Channel() {
+			msgChan <- []byte(msg.Payload)
+		}
+	}()
+
+	return msgChan, pubSub.Close
+}
+
+// observe - reports operation to hook, hit and err are read at the moment of call (use with defer)
+func (c *St) observe(op, key string, start time.Time, hit *bool, err *error) {
+	if c.hook == nil {
+		return
+	}
+
+	e := metrics.EventSt{
+		Backend:   metricsBackend,
+		Op:        op,
+		KeyPrefix: metrics.KeyPrefix(key),
+		Latency:   time.Since(start),
+		Err:       *err,
+	}
+
+	if hit != nil {
+		e.Lookup = true
+		e.Hit = *hit
+	}
+
+	c.hook.Observe(e)
+}
+
+func firstKey(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
 }
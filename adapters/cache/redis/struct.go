@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache/codec"
+	"github.com/rendau/dop/adapters/cache/metrics"
+)
+
+// Options
+
+type OptionsSt struct {
+	Mode string
+	// Addrs - single address for ModeSingle, sentinel or cluster nodes for others
+	Addrs      []string
+	MasterName string
+	Username   string
+	Password   string
+	Db         int
+	Prefix     string
+
+	SentinelUsername string
+	SentinelPassword string
+
+	TLSConfig *tls.Config
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+
+	PingTimeout time.Duration
+	SkipPing    bool
+
+	// Codec - used by GetJsonObj/SetJsonObj, json by default
+	Codec *codec.St
+	// Hook - receives every operation, nil - disabled
+	Hook metrics.Hook
+}
+
+func (o *OptionsSt) mergeWithDefaults() {
+	if o.Mode == "" {
+		o.Mode = defaultOptions.Mode
+	}
+	if o.PingTimeout == 0 {
+		o.PingTimeout = defaultOptions.PingTimeout
+	}
+	if o.Codec == nil {
+		o.Codec = codec.Default()
+	}
+}
@@ -0,0 +1,12 @@
+package tiered
+
+import (
+	"time"
+)
+
+const metricsBackend = "tiered"
+
+var defaultOptions = OptionsSt{
+	LocalTTL: 5 * time.Second,
+	Channel:  "cache-invalidate",
+}
@@ -0,0 +1,398 @@
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache"
+	"github.com/rendau/dop/adapters/cache/internal/rnd"
+	"github.com/rendau/dop/adapters/cache/metrics"
+	"github.com/rendau/dop/adapters/logger"
+)
+
+// St - local cache in front of remote one, writes are broadcast to other instances to evict their local copies
+type St struct {
+	lg   logger.WarnAndError
+	opts OptionsSt
+	id   string
+
+	local  cache.CacheV2
+	remote Remote
+
+	msgChan     <-chan []byte
+	unsubscribe func() error
+	doneChan    chan struct{}
+}
+
+var (
+	_ cache.CacheV2 = (*St)(nil)
+	_ cache.Cache   = cache.NewV1Adapter((*St)(nil))
+)
+
+func New(lg logger.WarnAndError, local cache.CacheV2, remote Remote, opts OptionsSt) *St {
+	opts.mergeWithDefaults()
+
+	id, err := rnd.Token()
+	if err != nil {
+		lg.Errorw("Tiered-cache: fail to generate instance id", err)
+	}
+
+	c := &St{
+		lg:       lg,
+		opts:     opts,
+		id:       id,
+		local:    local,
+		remote:   remote,
+		doneChan: make(chan struct{}),
+	}
+
+	c.msgChan, c.unsubscribe = remote.Subscribe(context.Background(), opts.Channel)
+
+	go c.subscriber()
+
+	return c
+}
+
+func (c *St) subscriber() {
+	defer close(c.doneChan)
+
+	var err error
+	var msg invalidateMsgSt
+
+	for rMsg := range c.msgChan {
+		msg = invalidateMsgSt{}
+
+		err = json.Unmarshal(rMsg, &msg)
+		if err != nil {
+			c.lg.Errorw("Tiered-cache: fail to unmarshal invalidate message", err)
+			continue
+		}
+
+		if msg.Src == c.id {
+			continue
+		}
+
+		for _, key := range msg.Keys {
+			_ = c.local.Del(context.Background(), key)
+		}
+
+		for _, pattern := range msg.Patterns {
+			_ = c.local.DelByPattern(context.Background(), pattern)
+		}
+	}
+}
+
+// Close - stops listening of invalidate messages
+func (c *St) Close() {
+	_ = c.unsubscribe()
+	<-c.doneChan
+}
+
+// AsCache - returns St as v1 cache.Cache (without context)
+func (c *St) AsCache() cache.Cache {
+	return cache.NewV1Adapter(c)
+}
+
+func (c *St) Get(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("get", key, time.Now(), &ok, &err)
+
+	data, ok, err = c.local.Get(ctx, key)
+	if err == nil && ok {
+		return data, true, nil
+	}
+
+	data, ok, err = c.remote.Get(ctx, key)
+	if err != nil || !ok {
+		return data, ok, err
+	}
+
+	_ = c.local.Set(ctx, key, data, c.localExpiration(0))
+
+	return data, true, nil
+}
+
+func (c *St) GetJsonObj(ctx context.Context, key string, dst any) (bool, error) {
+	dataRaw, ok, err := c.Get(ctx, key)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	err = c.opts.Codec.Decode(dataRaw, dst)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *St) Set(ctx context.Context, key string, value []byte, expiration time.Duration) (err error) {
+	defer c.observe("set", key, time.Now(), nil, &err)
+
+	err = c.remote.Set(ctx, key, value, expiration)
+	if err != nil {
+		_ = c.local.Del(ctx, key)
+		return err
+	}
+
+	_ = c.local.Set(ctx, key, value, c.localExpiration(expiration))
+
+	c.invalidate(ctx, key)
+
+	return nil
+}
+
+func (c *St) SetJsonObj(ctx context.Context, key string, value any, expiration time.Duration) error {
+	dataRaw, err := c.opts.Codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	return c.Set(ctx, key, dataRaw, expiration)
+}
+
+func (c *St) Del(ctx context.Context, key string) (err error) {
+	defer c.observe("del", key, time.Now(), nil, &err)
+
+	_ = c.local.Del(ctx, key)
+
+	err = c.remote.Del(ctx, key)
+
+	c.invalidate(ctx, key)
+
+	return err
+}
+
+func (c *St) Keys(ctx context.Context, pattern string) (keys []string, err error) {
+	defer c.observe("keys", pattern, time.Now(), nil, &err)
+
+	return c.remote.Keys(ctx, pattern)
+}
+
+func (c *St) MGet(ctx context.Context, keys []string) (result map[string][]byte, err error) {
+	start := time.Now()
+
+	defer func() {
+		// hit only if all keys found
+		hit := len(result) == len(keys)
+		c.observe("mget", firstKey(keys), start, &hit, &err)
+	}()
+
+	result, err = c.local.MGet(ctx, keys)
+	if err != nil {
+		result = map[string][]byte{}
+	}
+
+	if len(result) == len(keys) {
+		return result, nil
+	}
+
+	missingKeys := make([]string, 0, len(keys)-len(result))
+	for _, key := range keys {
+		if _, ok := result[key]; !ok {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+
+	remoteValues, err := c.remote.MGet(ctx, missingKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(remoteValues) > 0 {
+		_ = c.local.MSet(ctx, remoteValues, c.localExpiration(0))
+	}
+
+	for k, v := range remoteValues {
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+func (c *St) MSet(ctx context.Context, values map[string][]byte, expiration time.Duration) (err error) {
+	defer c.observe("mset", "", time.Now(), nil, &err)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	err = c.remote.MSet(ctx, values, expiration)
+	if err != nil {
+		for _, k := range keys {
+			_ = c.local.Del(ctx, k)
+		}
+		return err
+	}
+
+	_ = c.local.MSet(ctx, values, c.localExpiration(expiration))
+
+	c.invalidate(ctx, keys...)
+
+	return nil
+}
+
+func (c *St) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("setnx", key, time.Now(), nil, &err)
+
+	ok, err = c.remote.SetNX(ctx, key, value, expiration)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	_ = c.local.Set(ctx, key, value, c.localExpiration(expiration))
+
+	c.invalidate(ctx, key)
+
+	return true, nil
+}
+
+func (c *St) GetDel(ctx context.Context, key string) (data []byte, ok bool, err error) {
+	defer c.observe("getdel", key, time.Now(), &ok, &err)
+
+	_ = c.local.Del(ctx, key)
+
+	data, ok, err = c.remote.GetDel(ctx, key)
+	if err == nil && ok {
+		c.invalidate(ctx, key)
+	}
+
+	return data, ok, err
+}
+
+func (c *St) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, 1, expiration)
+}
+
+func (c *St) Decr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return c.IncrBy(ctx, key, -1, expiration)
+}
+
+func (c *St) IncrBy(ctx context.Context, key string, delta int64, expiration time.Duration) (v int64, err error) {
+	defer c.observe("incrby", key, time.Now(), nil, &err)
+
+	_ = c.local.Del(ctx, key)
+
+	v, err = c.remote.IncrBy(ctx, key, delta, expiration)
+	if err == nil {
+		c.invalidate(ctx, key)
+	}
+
+	return v, err
+}
+
+func (c *St) Expire(ctx context.Context, key string, expiration time.Duration) (ok bool, err error) {
+	defer c.observe("expire", key, time.Now(), nil, &err)
+
+	_ = c.local.Del(ctx, key)
+
+	ok, err = c.remote.Expire(ctx, key, expiration)
+	if err == nil && ok {
+		c.invalidate(ctx, key)
+	}
+
+	return ok, err
+}
+
+func (c *St) TTL(ctx context.Context, key string) (ttl time.Duration, ok bool, err error) {
+	defer c.observe("ttl", key, time.Now(), nil, &err)
+
+	return c.remote.TTL(ctx, key)
+}
+
+func (c *St) SetWithTags(ctx context.Context, key string, value []byte, expiration time.Duration, tags ...string) (err error) {
+	defer c.observe("setwithtags", key, time.Now(), nil, &err)
+
+	err = c.remote.SetWithTags(ctx, key, value, expiration, tags...)
+	if err != nil {
+		_ = c.local.Del(ctx, key)
+		return err
+	}
+
+	_ = c.local.Set(ctx, key, value, c.localExpiration(expiration))
+
+	c.invalidate(ctx, key)
+
+	return nil
+}
+
+func (c *St) InvalidateTags(ctx context.Context, tags ...string) (err error) {
+	defer c.observe("invalidatetags", "", time.Now(), nil, &err)
+
+	keys, err := c.remote.InvalidateTagsKeys(ctx, tags...)
+
+	if len(keys) > 0 {
+		for _, key := range keys {
+			_ = c.local.Del(ctx, key)
+		}
+
+		c.invalidate(ctx, keys...)
+	}
+
+	return err
+}
+
+func (c *St) DelByPattern(ctx context.Context, pattern string) (err error) {
+	defer c.observe("delbypattern", pattern, time.Now(), nil, &err)
+
+	_ = c.local.DelByPattern(ctx, pattern)
+
+	err = c.remote.DelByPattern(ctx, pattern)
+
+	c.publish(ctx, invalidateMsgSt{Patterns: []string{pattern}})
+
+	return err
+}
+
+func (c *St) localExpiration(expiration time.Duration) time.Duration {
+	if expiration > 0 && expiration < c.opts.LocalTTL {
+		return expiration
+	}
+	return c.opts.LocalTTL
+}
+
+func (c *St) observe(op, key string, start time.Time, hit *bool, err *error) {
+	if c.opts.Hook == nil {
+		return
+	}
+
+	e := metrics.EventSt{
+		Backend:   metricsBackend,
+		Op:        op,
+		KeyPrefix: metrics.KeyPrefix(key),
+		Latency:   time.Since(start),
+		Err:       *err,
+	}
+
+	if hit != nil {
+		e.Lookup = true
+		e.Hit = *hit
+	}
+
+	c.opts.Hook.Observe(e)
+}
+
+func (c *St) invalidate(ctx context.Context, keys ...string) {
+	c.publish(ctx, invalidateMsgSt{Keys: keys})
+}
+
+func (c *St) publish(ctx context.Context, msgObj invalidateMsgSt) {
+	msgObj.Src = c.id
+
+	msg, err := json.Marshal(msgObj)
+	if err != nil {
+		c.lg.Errorw("Tiered-cache: fail to marshal invalidate message", err)
+		return
+	}
+
+	_ = c.remote.Publish(ctx, c.opts.Channel, msg)
+}
+
+func firstKey(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
+}
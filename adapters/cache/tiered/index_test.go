@@ -0,0 +1,300 @@
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache/mem"
+	"github.com/rendau/dop/adapters/cache/metrics"
+	"github.com/rendau/dop/adapters/logger/zap"
+	"github.com/stretchr/testify/require"
+)
+
+var lg = zap.New("info", true)
+
+// remoteSt - fake Remote, mem cache with in-process pub/sub
+type remoteSt struct {
+	*mem.St
+
+	mu        sync.Mutex
+	subs      map[string][]chan []byte
+	published [][]byte
+}
+
+func newRemote() *remoteSt {
+	return &remoteSt{
+		St:   mem.New(),
+		subs: map[string][]chan []byte{},
+	}
+}
+
+func (r *remoteSt) InvalidateTagsKeys(ctx context.Context, tags ...string) ([]string, error) {
+	return nil, r.St.InvalidateTags(ctx, tags...)
+}
+
+func (r *remoteSt) Publish(ctx context.Context, channel string, msg []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.published = append(r.published, msg)
+
+	for _, ch := range r.subs[channel] {
+		ch <- msg
+	}
+
+	return nil
+}
+
+func (r *remoteSt) Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch := make(chan []byte, 100)
+
+	r.subs[channel] = append(r.subs[channel], ch)
+
+	return ch, func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for i, c := range r.subs[channel] {
+			if c == ch {
+				r.subs[channel] = append(r.subs[channel][:i], r.subs[channel][i+1:]...)
+				close(ch)
+				break
+			}
+		}
+
+		return nil
+	}
+}
+
+func (r *remoteSt) publishedMessages(t *testing.T) []invalidateMsgSt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]invalidateMsgSt, 0, len(r.published))
+
+	for _, raw := range r.published {
+		msg := invalidateMsgSt{}
+		require.Nil(t, json.Unmarshal(raw, &msg))
+		result = append(result, msg)
+	}
+
+	return result
+}
+
+func localValue(t *testing.T, c *St, key string) (string, bool) {
+	v, ok, err := c.local.Get(context.Background(), key)
+	require.Nil(t, err)
+	return string(v), ok
+}
+
+func TestLocalTTL(t *testing.T) {
+	ctx := context.Background()
+
+	remote := newRemote()
+
+	c := New(lg, mem.New(), remote, OptionsSt{LocalTTL: 30 * time.Millisecond})
+	defer c.Close()
+
+	err := c.Set(ctx, "k", []byte("v1"), time.Minute)
+	require.Nil(t, err)
+
+	// changed bypassing tiered cache, local copy is served until it expires
+	err = remote.Set(ctx, "k", []byte("v2"), time.Minute)
+	require.Nil(t, err)
+
+	v, ok, err := c.Get(ctx, "k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v1", string(v))
+
+	time.Sleep(40 * time.Millisecond)
+
+	v, ok, err = c.Get(ctx, "k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v2", string(v))
+
+	// local copy does not outlive shorter expiration
+	err = c.Set(ctx, "short", []byte("v"), 10*time.Millisecond)
+	require.Nil(t, err)
+
+	time.Sleep(15 * time.Millisecond)
+
+	_, ok = localValue(t, c, "short")
+	require.False(t, ok)
+}
+
+func TestBroadcast(t *testing.T) {
+	ctx := context.Background()
+
+	remote := newRemote()
+
+	c1 := New(lg, mem.New(), remote, OptionsSt{LocalTTL: time.Minute})
+	defer c1.Close()
+
+	c2 := New(lg, mem.New(), remote, OptionsSt{LocalTTL: time.Minute})
+	defer c2.Close()
+
+	err := c1.Set(ctx, "k", []byte("v1"), 0)
+	require.Nil(t, err)
+
+	v, ok, err := c2.Get(ctx, "k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v1", string(v))
+
+	// set on c1 evicts local copy of c2
+	err = c1.Set(ctx, "k", []byte("v2"), 0)
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		_, ok := localValue(t, c2, "k")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+
+	v, ok, err = c2.Get(ctx, "k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v2", string(v))
+
+	// own message does not evict local copy of sender
+	lv, ok := localValue(t, c1, "k")
+	require.True(t, ok)
+	require.Equal(t, "v2", lv)
+
+	// del on c1 evicts local copy of c2
+	err = c1.Del(ctx, "k")
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		_, ok := localValue(t, c2, "k")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok, err = c2.Get(ctx, "k")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	msgs := remote.publishedMessages(t)
+	require.Len(t, msgs, 3)
+	for _, msg := range msgs {
+		require.Equal(t, c1.id, msg.Src)
+		require.Equal(t, []string{"k"}, msg.Keys)
+	}
+}
+
+func TestInvalidateMessage(t *testing.T) {
+	ctx := context.Background()
+
+	remote := newRemote()
+
+	c := New(lg, mem.New(), remote, OptionsSt{LocalTTL: time.Minute})
+	defer c.Close()
+
+	for _, key := range []string{"k1", "k2", "p:1", "p:2"} {
+		err := c.Set(ctx, key, []byte("v"), 0)
+		require.Nil(t, err)
+	}
+
+	publish := func(msg invalidateMsgSt) {
+		raw, err := json.Marshal(msg)
+		require.Nil(t, err)
+		require.Nil(t, remote.Publish(ctx, defaultOptions.Channel, raw))
+	}
+
+	publish(invalidateMsgSt{Src: "other", Keys: []string{"k1"}})
+
+	require.Eventually(t, func() bool {
+		_, ok := localValue(t, c, "k1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok := localValue(t, c, "k2")
+	require.True(t, ok)
+
+	publish(invalidateMsgSt{Src: "other", Patterns: []string{"p:*"}})
+
+	require.Eventually(t, func() bool {
+		_, ok1 := localValue(t, c, "p:1")
+		_, ok2 := localValue(t, c, "p:2")
+		return !ok1 && !ok2
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok = localValue(t, c, "k2")
+	require.True(t, ok)
+}
+
+func TestAsCache(t *testing.T) {
+	remote := newRemote()
+
+	c := New(lg, mem.New(), remote, OptionsSt{})
+	defer c.Close()
+
+	v1 := c.AsCache()
+
+	err := v1.Set("k", []byte("v"), 0)
+	require.Nil(t, err)
+
+	v, ok, err := v1.Get("k")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v", string(v))
+
+	require.Equal(t, []string{"k"}, v1.Keys("*"))
+
+	err = v1.Del("k")
+	require.Nil(t, err)
+
+	_, ok, err = v1.Get("k")
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func TestHook(t *testing.T) {
+	ctx := context.Background()
+
+	events := make([]metrics.EventSt, 0)
+
+	c := New(lg, mem.New(), newRemote(), OptionsSt{
+		Hook: metrics.HookFunc(func(e metrics.EventSt) {
+			events = append(events, e)
+		}),
+	})
+	defer c.Close()
+
+	err := c.Set(ctx, "user:1", []byte("v"), 0)
+	require.Nil(t, err)
+
+	_, ok, err := c.Get(ctx, "user:1")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, ok, err = c.Get(ctx, "user:2")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	_, err = c.MGet(ctx, []string{"user:1", "user:2"})
+	require.Nil(t, err)
+
+	// only operations of tiered cache are reported, not of its local and remote
+	require.Len(t, events, 4)
+
+	for _, e := range events {
+		require.Equal(t, "tiered", e.Backend)
+	}
+
+	require.Equal(t, "set", events[0].Op)
+	require.Equal(t, "user", events[0].KeyPrefix)
+	require.Equal(t, metrics.ResultOk, events[0].Result())
+	require.Equal(t, "get", events[1].Op)
+	require.Equal(t, metrics.ResultHit, events[1].Result())
+	require.Equal(t, metrics.ResultMiss, events[2].Result())
+	require.Equal(t, "mget", events[3].Op)
+	require.Equal(t, metrics.ResultMiss, events[3].Result())
+}
@@ -0,0 +1,48 @@
+package tiered
+
+import (
+	"context"
+	"time"
+
+	"github.com/rendau/dop/adapters/cache"
+	"github.com/rendau/dop/adapters/cache/codec"
+	"github.com/rendau/dop/adapters/cache/metrics"
+)
+
+type Remote interface {
+	cache.CacheV2
+	InvalidateTagsKeys(ctx context.Context, tags ...string) ([]string, error)
+	Publish(ctx context.Context, channel string, msg []byte) error
+	// Subscribe - returns channel of message payloads, it must be closed after unsubscribe
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, func() error)
+}
+
+// Options
+
+type OptionsSt struct {
+	// LocalTTL - max lifetime of local copies, bounds staleness if invalidation message is lost
+	LocalTTL time.Duration
+	Channel  string
+	// Codec - used by GetJsonObj/SetJsonObj, json by default
+	Codec *codec.St
+	// Hook - receives every operation (local and remote together), nil - disabled
+	Hook metrics.Hook
+}
+
+func (o *OptionsSt) mergeWithDefaults() {
+	if o.LocalTTL == 0 {
+		o.LocalTTL = defaultOptions.LocalTTL
+	}
+	if o.Channel == "" {
+		o.Channel = defaultOptions.Channel
+	}
+	if o.Codec == nil {
+		o.Codec = codec.Default()
+	}
+}
+
+type invalidateMsgSt struct {
+	Src      string   `json:"src"`
+	Keys     []string `json:"keys,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
@@ -0,0 +1,66 @@
+package httpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Compress - compresses data with CompressionGzip or CompressionDeflate
+func Compress(data []byte, encoding string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	w, err := newCompressWriter(buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CompressStream - returns r compressed on the fly, r is closed if io.Closer.
+// Result must be read to end or closed
+func CompressStream(r io.Reader, encoding string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+
+	w, err := newCompressWriter(pw, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_, err := io.Copy(w, r)
+		if err == nil {
+			err = w.Close()
+		}
+		if rc, ok := r.(io.Closer); ok {
+			_ = rc.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+func newCompressWriter(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionDeflate:
+		return zlib.NewWriter(w), nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %q", encoding)
+}
@@ -1,5 +1,11 @@
 package httpc
 
+import (
+	"time"
+
+	"github.com/rendau/dop/dopErrs"
+)
+
 const (
 	LogRequest            = 1
 	LogResponse           = 2
@@ -9,3 +15,57 @@ const (
 	NoLogPermissionDenied = 32
 	NoLogBadStatus        = 64
 )
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+const (
+	CompressionGzip    = "gzip"
+	CompressionDeflate = "deflate"
+)
+
+const (
+	ErrResponseTooLarge = dopErrs.Err("response_too_large")
+	// ErrRateLimited - deadline of context comes before turn of request in rate limit, it is not retried and not counted by breaker
+	ErrRateLimited = dopErrs.Err("rate_limited")
+)
+
+const defaultRetryMultiplier = 2
+
+const defaultLogBodyMaxSize = 4096
+
+const logRedactedValue = "***"
+
+// DefaultLogRedactHeaders - headers which values are hidden in logs
+var DefaultLogRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// DefaultLogRedactFields - json fields and params which values are hidden in logs
+var DefaultLogRedactFields = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"access_token",
+	"refresh_token",
+	"client_secret",
+	"api_key",
+}
+
+var defaultLimitOptions = LimitOptionsSt{
+	Burst: 1,
+}
+
+var defaultBreakerOptions = BreakerOptionsSt{
+	FailureThreshold: 5,
+	CoolDown:         30 * time.Second,
+	HalfOpenMaxCalls: 1,
+}
@@ -0,0 +1,70 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/rendau/dop/dopErrs"
+)
+
+// Do - sends req as json and decodes response into Rep,
+// bad status code is returned as *StatusErr[json.RawMessage]
+func Do[Req, Rep any](ctx context.Context, client HttpC, opts *OptionsSt, req Req) (Rep, *RespSt, error) {
+	return DoWithErr[Rep, json.RawMessage](ctx, client, opts, req)
+}
+
+// DoWithErr - same as Do, bad status code is returned as *StatusErr[ErrRep] with decoded body
+func DoWithErr[Rep, ErrRep any](ctx context.Context, client HttpC, opts *OptionsSt, req any) (Rep, *RespSt, error) {
+	var rep Rep
+
+	reqOpts := OptionsSt{}
+	if opts != nil {
+		reqOpts = *opts
+	}
+
+	if !isNil(req) {
+		reqOpts.ReqObj = req
+	}
+	reqOpts.RepObj = &rep
+
+	resp, err := client.SendCtx(ctx, &reqOpts)
+	if err != nil {
+		if resp != nil && errors.Is(err, dopErrs.BadStatusCode) {
+			return rep, resp, newStatusErr[ErrRep](resp)
+		}
+		return rep, resp, err
+	}
+
+	return rep, resp, nil
+}
+
+func newStatusErr[T any](resp *RespSt) *StatusErr[T] {
+	res := &StatusErr[T]{
+		StatusCode: resp.StatusCode,
+		BodyRaw:    resp.BodyRaw,
+	}
+
+	if len(resp.BodyRaw) > 0 {
+		// body of unexpected format is available in BodyRaw
+		res.BodyDecoded = json.Unmarshal(resp.BodyRaw, &res.Body) == nil
+	}
+
+	return res
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,220 @@
+package fakesrv
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+)
+
+// St - httptest server emulating json http service, records requests and plays scripted failures
+type St struct {
+	srv *httptest.Server
+
+	handlers map[string]HandlerFn
+	prefixes map[string]HandlerFn
+	requests []*RequestSt
+	failures map[string][]FailureSt
+	mu       sync.Mutex
+}
+
+func New() *St {
+	s := &St{
+		handlers: map[string]HandlerFn{},
+		prefixes: map[string]HandlerFn{},
+		requests: []*RequestSt{},
+		failures: map[string][]FailureSt{},
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+// Handle - registers handler for "METHOD path", path ending with "/" matches all nested paths (the longest one wins)
+func (s *St) Handle(method, path string, h HandlerFn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := routeKey(method, path)
+
+	if strings.HasSuffix(path, "/") {
+		s.prefixes[key] = h
+	} else {
+		s.handlers[key] = h
+	}
+}
+
+func (s *St) URL() string {
+	return s.srv.URL
+}
+
+// ClientOptions - base options for httpclient.New
+func (s *St) ClientOptions() *httpc.OptionsSt {
+	return &httpc.OptionsSt{
+		Client: s.srv.Client(),
+		Uri:    s.srv.URL,
+	}
+}
+
+func (s *St) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// Fail - next requests to "METHOD path" fail in given order
+func (s *St) Fail(method, path string, failures ...FailureSt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := routeKey(method, path)
+
+	s.failures[key] = append(s.failures[key], failures...)
+}
+
+func (s *St) Requests() []*RequestSt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]*RequestSt, len(s.requests))
+	copy(result, s.requests)
+
+	return result
+}
+
+// RequestCount - count of requests to path including failed ones
+func (s *St) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path = strings.TrimLeft(path, "/")
+
+	result := 0
+
+	for _, req := range s.requests {
+		if req.Path == path {
+			result++
+		}
+	}
+
+	return result
+}
+
+// Reset - removes recorded requests and pending failures
+func (s *St) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = []*RequestSt{}
+	s.failures = map[string][]FailureSt{}
+}
+
+func (s *St) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	req := &RequestSt{
+		Method:  r.Method,
+		Path:    strings.TrimLeft(r.URL.Path, "/"),
+		Query:   r.URL.Query(),
+		Headers: r.Header.Clone(),
+		Body:    body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+
+	var failure *FailureSt
+	if q := s.failures[routeKey(req.Method, req.Path)]; len(q) > 0 {
+		failure = &q[0]
+		s.failures[routeKey(req.Method, req.Path)] = q[1:]
+	}
+
+	h := s.findHandler(req)
+	s.mu.Unlock()
+
+	if failure != nil {
+		s.fail(w, r, failure)
+		return
+	}
+
+	if h == nil {
+		writeJson(w, http.StatusNotFound, map[string]string{"error_code": "page_not_found"})
+		return
+	}
+
+	statusCode, repObj := h(req)
+
+	writeJson(w, statusCode, repObj)
+}
+
+// findHandler - must be called under lock
+func (s *St) findHandler(req *RequestSt) HandlerFn {
+	key := routeKey(req.Method, req.Path)
+
+	if h := s.handlers[key]; h != nil {
+		return h
+	}
+
+	var result HandlerFn
+	resultLen := 0
+
+	for prefix, h := range s.prefixes {
+		if len(prefix) > resultLen && strings.HasPrefix(key, prefix) {
+			result = h
+			resultLen = len(prefix)
+		}
+	}
+
+	return result
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + strings.TrimLeft(path, "/")
+}
+
+func (s *St) fail(w http.ResponseWriter, r *http.Request, f *FailureSt) {
+	if f.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(f.Delay):
+		}
+	}
+
+	if f.CloseConn {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+
+	statusCode := f.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+
+	writeJson(w, statusCode, f.Body)
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, obj any) {
+	if obj == nil {
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	_ = json.NewEncoder(w).Encode(obj)
+}
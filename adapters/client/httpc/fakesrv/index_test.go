@@ -0,0 +1,183 @@
+package fakesrv
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/adapters/client/httpc/httpclient"
+	"github.com/rendau/dop/adapters/jwt"
+	"github.com/rendau/dop/adapters/jwt/jwts"
+	"github.com/rendau/dop/adapters/krp/krps"
+	"github.com/rendau/dop/adapters/logger/zap"
+	"github.com/rendau/dop/adapters/mail"
+	"github.com/rendau/dop/adapters/mail/mails"
+	"github.com/rendau/dop/adapters/sms/smss"
+	"github.com/rendau/dop/adapters/ws"
+	"github.com/rendau/dop/adapters/ws/websocket"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/stretchr/testify/require"
+)
+
+var lg = zap.New("info", true)
+
+func newClient(s *St, opts *httpc.OptionsSt) *httpclient.St {
+	base := s.ClientOptions()
+	base.LogFlags = httpc.NoLogError
+	if opts != nil {
+		base = base.GetMergedWith(opts)
+		base.Uri = s.URL()
+	}
+
+	return httpclient.New(lg, base)
+}
+
+func TestRouting(t *testing.T) {
+	srv := New()
+	defer srv.Close()
+
+	handler := func(name string) HandlerFn {
+		return func(req *RequestSt) (int, any) {
+			return http.StatusOK, map[string]string{"h": name}
+		}
+	}
+
+	srv.Handle(http.MethodGet, "a/", handler("a"))
+	srv.Handle(http.MethodGet, "a/b/c/", handler("abc"))
+	srv.Handle(http.MethodGet, "a/b/", handler("ab"))
+	srv.Handle(http.MethodGet, "a/b/c/d", handler("abcd"))
+
+	c := newClient(srv, nil)
+
+	get := func(path string) string {
+		repObj := map[string]string{}
+		_, err := c.Send(&httpc.OptionsSt{Uri: path, RepObj: &repObj})
+		require.Nil(t, err)
+		return repObj["h"]
+	}
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, "a", get("a/x"))
+		require.Equal(t, "ab", get("a/b/x"))
+		require.Equal(t, "abc", get("a/b/c/x"))
+		require.Equal(t, "abcd", get("a/b/c/d"))
+	}
+
+	// failure is bound to method
+	srv.Reset()
+	srv.Fail(http.MethodPost, "a/x", FailureSt{StatusCode: http.StatusBadGateway})
+	require.Equal(t, "a", get("a/x"))
+
+	_, err := c.Send(&httpc.OptionsSt{Method: http.MethodPost, Uri: "a/x"})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.Equal(t, 2, srv.RequestCount("a/x"))
+}
+
+func TestSms(t *testing.T) {
+	srv := NewSms()
+	defer srv.Close()
+
+	sms := smss.New(newClient(srv.St, &httpc.OptionsSt{
+		RetryCount:    2,
+		RetryInterval: time.Millisecond,
+		Headers:       http.Header{"Idempotency-Key": {"sms"}}, // POST is retried only if it is idempotent
+		Timeout:       100 * time.Millisecond,
+	}))
+
+	require.True(t, sms.Send("77011111111", "code 1234"))
+	require.Equal(t, []smss.SendReqSt{{To: "77011111111", Text: "code 1234", Sync: true}}, srv.Sent())
+
+	// recovers after failures
+	srv.Fail(http.MethodPost, "send", FailureSt{CloseConn: true}, FailureSt{Delay: time.Second})
+	require.True(t, sms.SendAsync("77011111111", "hi"))
+	require.Equal(t, 4, srv.RequestCount("send"))
+	require.Len(t, srv.Sent(), 2)
+
+	// retries exhausted
+	srv.Reset()
+	srv.Fail(http.MethodPost, "send", FailureSt{}, FailureSt{}, FailureSt{})
+	require.False(t, sms.Send("77011111111", "hi"))
+	require.Equal(t, 3, srv.RequestCount("send"))
+	require.Empty(t, srv.Sent())
+}
+
+func TestMail(t *testing.T) {
+	srv := NewMail()
+	defer srv.Close()
+
+	m := mails.New(newClient(srv.St, nil))
+
+	req := &mail.SendReqSt{Receivers: []string{"a@b.c"}, Subject: "s", Message: "m"}
+
+	require.True(t, m.Send(req))
+	require.Equal(t, []mail.SendReqSt{*req}, srv.Sent())
+
+	srv.Fail(http.MethodPost, "send", FailureSt{StatusCode: http.StatusBadRequest, Body: map[string]string{"error_code": "bad_receivers"}})
+	require.False(t, m.Send(req))
+	require.Len(t, srv.Sent(), 1)
+}
+
+func TestJwt(t *testing.T) {
+	srv := NewJwt()
+	defer srv.Close()
+
+	j := jwts.New(newClient(srv.St, nil))
+
+	token, err := j.Create("usr1", 60, map[string]any{"role": "admin"})
+	require.Nil(t, err)
+
+	claims := struct {
+		Sub  string `json:"sub"`
+		Role string `json:"role"`
+		Exp  int64  `json:"exp"`
+	}{}
+	require.Nil(t, jwt.ParsePayload(token, &claims))
+	require.Equal(t, "usr1", claims.Sub)
+	require.Equal(t, "admin", claims.Role)
+	require.InDelta(t, time.Now().Unix()+60, claims.Exp, 5)
+	require.Len(t, srv.Issued(), 1)
+
+	srv.Fail(http.MethodPost, "jwt", FailureSt{StatusCode: http.StatusServiceUnavailable})
+	_, err = j.Create("usr1", 60, nil)
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+}
+
+func TestKrp(t *testing.T) {
+	srv := NewKrp()
+	defer srv.Close()
+
+	k := krps.New(lg, newClient(srv.St, nil))
+
+	require.Nil(t, k.SendManyJson("events", "k1", []any{map[string]any{"a": 1.0}, "v2"}))
+	require.Equal(t, []krps.SendReqRecordSt{
+		{Key: "k1", Value: map[string]any{"a": 1.0}},
+		{Key: "k1", Value: "v2"},
+	}, srv.Records("events"))
+	require.Empty(t, srv.Records("other"))
+
+	srv.Fail(http.MethodPost, "topics/events", FailureSt{StatusCode: http.StatusBadGateway})
+	require.ErrorIs(t, k.SendJson("events", "k1", 1), dopErrs.BadStatusCode)
+	require.Len(t, srv.Records("events"), 2)
+}
+
+func TestWs(t *testing.T) {
+	srv := NewWs()
+	defer srv.Close()
+
+	w := websocket.New(newClient(srv.St, nil))
+
+	require.Nil(t, w.Send2User(7, map[string]any{"type": "ping"}))
+	require.Equal(t, []ws.SendReqSt{{UsrIds: []int64{7}, Message: map[string]any{"type": "ping"}}}, srv.Sent())
+
+	srv.SetConnectionCount(42)
+
+	cnt, err := w.GetConnectionCount()
+	require.Nil(t, err)
+	require.EqualValues(t, 42, cnt)
+
+	srv.Fail(http.MethodPost, "connection_count", FailureSt{StatusCode: http.StatusBadRequest})
+	_, err = w.GetConnectionCount()
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.Equal(t, 2, srv.RequestCount("connection_count"))
+}
@@ -0,0 +1,289 @@
+package fakesrv
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rendau/dop/adapters/krp/krps"
+	"github.com/rendau/dop/adapters/mail"
+	"github.com/rendau/dop/adapters/sms/smss"
+	"github.com/rendau/dop/adapters/ws"
+)
+
+func badRequest(err error) (int, any) {
+	return http.StatusBadRequest, map[string]string{"error_code": "bad_json", "desc": err.Error()}
+}
+
+// Sms - emulates sms service ("POST send") used by smss
+
+type SmsSt struct {
+	*St
+
+	sent []smss.SendReqSt
+	mu   sync.Mutex
+}
+
+func NewSms() *SmsSt {
+	s := &SmsSt{St: New()}
+
+	s.Handle(http.MethodPost, "send", func(req *RequestSt) (int, any) {
+		obj := smss.SendReqSt{}
+		if err := req.DecodeJson(&obj); err != nil {
+			return badRequest(err)
+		}
+
+		s.mu.Lock()
+		s.sent = append(s.sent, obj)
+		id := len(s.sent)
+		s.mu.Unlock()
+
+		return http.StatusOK, smss.SendRepSt{ID: strconv.Itoa(id)}
+	})
+
+	return s
+}
+
+// Sent - successfully handled messages
+func (s *SmsSt) Sent() []smss.SendReqSt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]smss.SendReqSt{}, s.sent...)
+}
+
+func (s *SmsSt) Reset() {
+	s.St.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = nil
+}
+
+// Mail - emulates mail service ("POST send") used by mails
+
+type MailSt struct {
+	*St
+
+	sent []mail.SendReqSt
+	mu   sync.Mutex
+}
+
+func NewMail() *MailSt {
+	s := &MailSt{St: New()}
+
+	s.Handle(http.MethodPost, "send", func(req *RequestSt) (int, any) {
+		obj := mail.SendReqSt{}
+		if err := req.DecodeJson(&obj); err != nil {
+			return badRequest(err)
+		}
+
+		s.mu.Lock()
+		s.sent = append(s.sent, obj)
+		s.mu.Unlock()
+
+		return http.StatusOK, nil
+	})
+
+	return s
+}
+
+// Sent - successfully handled mails
+func (s *MailSt) Sent() []mail.SendReqSt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]mail.SendReqSt{}, s.sent...)
+}
+
+func (s *MailSt) Reset() {
+	s.St.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = nil
+}
+
+// Jwt - emulates jwt service ("POST jwt") used by jwts, tokens are not signed
+
+type JwtSt struct {
+	*St
+
+	issued []map[string]any
+	mu     sync.Mutex
+}
+
+func NewJwt() *JwtSt {
+	s := &JwtSt{St: New()}
+
+	s.Handle(http.MethodPost, "jwt", func(req *RequestSt) (int, any) {
+		claims := map[string]any{}
+		if err := req.DecodeJson(&claims); err != nil {
+			return badRequest(err)
+		}
+
+		if expSeconds, ok := claims["exp_seconds"].(float64); ok {
+			delete(claims, "exp_seconds")
+			claims["exp"] = time.Now().Unix() + int64(expSeconds)
+		}
+
+		payloadRaw, err := json.Marshal(claims)
+		if err != nil {
+			return badRequest(err)
+		}
+
+		s.mu.Lock()
+		s.issued = append(s.issued, claims)
+		s.mu.Unlock()
+
+		return http.StatusOK, map[string]string{
+			"token": base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." +
+				base64.RawURLEncoding.EncodeToString(payloadRaw) + ".fake",
+		}
+	})
+
+	return s
+}
+
+// Issued - claims of issued tokens, "exp_seconds" is converted to "exp"
+func (s *JwtSt) Issued() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]map[string]any{}, s.issued...)
+}
+
+func (s *JwtSt) Reset() {
+	s.St.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.issued = nil
+}
+
+// Krp - emulates kafka rest proxy ("POST topics/{topic}") used by krps
+
+type KrpSt struct {
+	*St
+
+	records map[string][]krps.SendReqRecordSt
+	mu      sync.Mutex
+}
+
+func NewKrp() *KrpSt {
+	s := &KrpSt{
+		St:      New(),
+		records: map[string][]krps.SendReqRecordSt{},
+	}
+
+	s.Handle(http.MethodPost, "topics/", func(req *RequestSt) (int, any) {
+		if !strings.HasPrefix(req.Headers.Get("Content-Type"), "application/vnd.kafka.json.") {
+			return http.StatusUnsupportedMediaType, map[string]any{"error_code": 415, "message": "unsupported media type"}
+		}
+
+		obj := krps.SendReqSt{}
+		if err := req.DecodeJson(&obj); err != nil {
+			return badRequest(err)
+		}
+
+		topic := strings.TrimPrefix(req.Path, "topics/")
+
+		offsets := make([]map[string]int, len(obj.Records))
+
+		s.mu.Lock()
+		for i, r := range obj.Records {
+			offsets[i] = map[string]int{"partition": 0, "offset": len(s.records[topic])}
+			s.records[topic] = append(s.records[topic], r)
+		}
+		s.mu.Unlock()
+
+		return http.StatusOK, map[string]any{"offsets": offsets}
+	})
+
+	return s
+}
+
+// Records - successfully produced records of topic, values are decoded from json
+func (s *KrpSt) Records(topic string) []krps.SendReqRecordSt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]krps.SendReqRecordSt{}, s.records[topic]...)
+}
+
+func (s *KrpSt) Reset() {
+	s.St.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = map[string][]krps.SendReqRecordSt{}
+}
+
+// Ws - emulates websocket service ("POST send", "POST connection_count") used by websocket
+
+type WsSt struct {
+	*St
+
+	sent            []ws.SendReqSt
+	connectionCount int64
+	mu              sync.Mutex
+}
+
+func NewWs() *WsSt {
+	s := &WsSt{St: New()}
+
+	s.Handle(http.MethodPost, "send", func(req *RequestSt) (int, any) {
+		obj := ws.SendReqSt{}
+		if err := req.DecodeJson(&obj); err != nil {
+			return badRequest(err)
+		}
+
+		s.mu.Lock()
+		s.sent = append(s.sent, obj)
+		s.mu.Unlock()
+
+		return http.StatusOK, nil
+	})
+
+	s.Handle(http.MethodPost, "connection_count", func(req *RequestSt) (int, any) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return http.StatusOK, ws.ConnectionCountRepSt{Value: s.connectionCount}
+	})
+
+	return s
+}
+
+func (s *WsSt) SetConnectionCount(v int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connectionCount = v
+}
+
+// Sent - successfully handled messages
+func (s *WsSt) Sent() []ws.SendReqSt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]ws.SendReqSt{}, s.sent...)
+}
+
+func (s *WsSt) Reset() {
+	s.St.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = nil
+	s.connectionCount = 0
+}
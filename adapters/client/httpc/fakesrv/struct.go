@@ -0,0 +1,35 @@
+package fakesrv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type RequestSt struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    []byte
+}
+
+func (r *RequestSt) DecodeJson(dst any) error {
+	return json.Unmarshal(r.Body, dst)
+}
+
+// FailureSt - scripted failure, one per request
+type FailureSt struct {
+	// StatusCode - 500 by default
+	StatusCode int
+	// Body - written as json if not nil
+	Body any
+	// Delay - sleep before response, e.g. to trigger client timeout
+	Delay time.Duration
+	// CloseConn - close connection without response (network error)
+	CloseConn bool
+}
+
+// HandlerFn - returns status code and json body of successful response
+type HandlerFn func(req *RequestSt) (int, any)
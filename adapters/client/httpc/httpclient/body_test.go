@@ -0,0 +1,175 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"name":"` + strings.Repeat("a", 100) + `"}`))
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:        http.DefaultClient,
+		Uri:           srv.URL,
+		LogFlags:      httpc.NoLogError,
+		RetryCount:    2,
+		RetryInterval: time.Millisecond,
+	})
+
+	rep := struct {
+		Name string `json:"name"`
+	}{}
+
+	// exact size
+	resp, err := hc.Send(&httpc.OptionsSt{MaxResponseBytes: 111, RepObj: &rep})
+	require.Nil(t, err)
+	require.Len(t, resp.BodyRaw, 111)
+	require.Len(t, rep.Name, 100)
+
+	// too large, not retried
+	atomic.StoreInt32(&calls, 0)
+	_, err = hc.Send(&httpc.OptionsSt{MaxResponseBytes: 50})
+	require.ErrorIs(t, err, httpc.ErrResponseTooLarge)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	_, err = hc.Send(&httpc.OptionsSt{MaxResponseBytes: 50, RepObj: &rep, RepObjStream: true})
+	require.ErrorIs(t, err, httpc.ErrResponseTooLarge)
+
+	resp, err = hc.Send(&httpc.OptionsSt{MaxResponseBytes: 50, RepStream: true})
+	require.Nil(t, err)
+	_, err = io.ReadAll(resp.Stream)
+	require.ErrorIs(t, err, httpc.ErrResponseTooLarge)
+	require.Nil(t, resp.Stream.Close())
+}
+
+func TestRepObjStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case "/empty":
+		case "/bad_json":
+			_, _ = w.Write([]byte(`{"id":`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error_code":"bad"}`))
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:       http.DefaultClient,
+		Uri:          srv.URL,
+		LogFlags:     httpc.NoLogError,
+		RepObjStream: true,
+	})
+
+	rep := struct {
+		Id int64 `json:"id"`
+	}{}
+
+	resp, err := hc.Send(&httpc.OptionsSt{Uri: "ok", RepObj: &rep})
+	require.Nil(t, err)
+	require.EqualValues(t, 7, rep.Id)
+	require.Nil(t, resp.BodyRaw)
+	require.Nil(t, resp.Stream)
+
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "empty", RepObj: &rep})
+	require.Nil(t, err)
+
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "bad_json", RepObj: &rep})
+	require.NotNil(t, err)
+
+	// body of bad status is kept
+	resp, err = hc.Send(&httpc.OptionsSt{Uri: "fail", RepObj: &rep})
+	require.NotNil(t, err)
+	require.Equal(t, `{"error_code":"bad"}`, string(resp.BodyRaw))
+}
+
+func TestReqCompression(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+
+		var body io.Reader
+		var err error
+
+		switch r.Header.Get("Content-Encoding") {
+		case httpc.CompressionGzip:
+			body, err = gzip.NewReader(r.Body)
+		case httpc.CompressionDeflate:
+			body, err = zlib.NewReader(r.Body)
+		default:
+			err = errors.New("no encoding")
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		data, err := io.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Path == "/retry" && n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:         http.DefaultClient,
+		Uri:            srv.URL,
+		Method:         "POST",
+		LogFlags:       httpc.NoLogError,
+		ReqCompression: httpc.CompressionGzip,
+	})
+
+	resp, err := hc.Send(&httpc.OptionsSt{ReqObj: map[string]int{"a": 1}})
+	require.Nil(t, err)
+	require.Equal(t, `{"a":1}`, string(resp.BodyRaw))
+	require.Equal(t, `{"a":1}`, string(resp.ReqOpts.ReqBody))
+
+	resp, err = hc.Send(&httpc.OptionsSt{ReqCompression: httpc.CompressionDeflate, ReqStream: strings.NewReader("stream")})
+	require.Nil(t, err)
+	require.Equal(t, "stream", string(resp.BodyRaw))
+
+	// compressed body is retryable
+	atomic.StoreInt32(&calls, 0)
+	resp, err = hc.Send(&httpc.OptionsSt{
+		Uri:           "retry",
+		Headers:       http.Header{"Idempotency-Key": {"k1"}},
+		ReqBody:       []byte("data"),
+		RetryCount:    1,
+		RetryInterval: time.Millisecond,
+	})
+	require.Nil(t, err)
+	require.Equal(t, "data", string(resp.BodyRaw))
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	_, err = hc.Send(&httpc.OptionsSt{ReqCompression: "br", ReqBody: []byte("data")})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopErrs"
+)
+
+type breakerSt struct {
+	state string
+	// generation - changed on every state transition, results of requests allowed in earlier one are ignored
+	generation uint64
+	failures   int
+	openedAt   time.Time
+	probes     int
+	mu         sync.Mutex
+}
+
+func (c *St) getBreaker(key string) *breakerSt {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+
+	b := c.breakers[key]
+	if b == nil {
+		b = &breakerSt{state: httpc.BreakerStateClosed}
+		c.breakers[key] = b
+	}
+
+	return b
+}
+
+// BreakerStates - states of circuit breakers by upstream key, for health endpoints
+func (c *St) BreakerStates() map[string]httpc.BreakerStateSt {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+
+	result := make(map[string]httpc.BreakerStateSt, len(c.breakers))
+
+	for k, b := range c.breakers {
+		b.mu.Lock()
+		result[k] = httpc.BreakerStateSt{
+			State:    b.state,
+			Failures: b.failures,
+			OpenedAt: b.openedAt,
+		}
+		b.mu.Unlock()
+	}
+
+	return result
+}
+
+// withBreaker - wraps round trip, fails fast with ServiceNA while circuit is open
+func (c *St) withBreaker(rt httpc.RoundTripFn, opts *httpc.OptionsSt) httpc.RoundTripFn {
+	bOpts := opts.Breaker.GetMergedWithDefaults()
+	b := c.getBreaker(breakerKey(opts))
+
+	isFailure := bOpts.IsFailure
+	if isFailure == nil {
+		isFailure = httpc.DefaultBreakerIsFailure
+	}
+
+	return func(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
+		generation, ok := b.allow(bOpts, time.Now())
+		if !ok {
+			resp.Reset()
+			return dopErrs.ServiceNA
+		}
+
+		err := rt(opts, resp)
+
+		// canceled by caller, not by upstream
+		if opts.Context().Err() != nil {
+			b.cancel(generation)
+			return err
+		}
+
+		b.done(bOpts, generation, isFailure(resp, err), time.Now())
+
+		return err
+	}
+}
+
+func breakerKey(opts *httpc.OptionsSt) string {
+	if opts.Breaker.Key != nil {
+		return opts.Breaker.Key(opts)
+	}
+
+	u, err := url.Parse(opts.Uri)
+	if err != nil {
+		return opts.Uri
+	}
+
+	return u.Host
+}
+
+// allow - returns false if request must fail fast, generation must be passed to done or cancel
+func (b *breakerSt) allow(bOpts *httpc.BreakerOptionsSt, now time.Time) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case httpc.BreakerStateOpen:
+		if now.Sub(b.openedAt) < bOpts.CoolDown {
+			return 0, false
+		}
+		b.setState(httpc.BreakerStateHalfOpen)
+		b.probes = 0
+		fallthrough
+	case httpc.BreakerStateHalfOpen:
+		if b.probes >= bOpts.HalfOpenMaxCalls {
+			return 0, false
+		}
+		b.probes++
+	}
+
+	return b.generation, true
+}
+
+// done - result of request allowed in earlier generation is ignored,
+// so late response can not close circuit or extend cool-down
+func (b *breakerSt) done(bOpts *httpc.BreakerOptionsSt, generation uint64, failure bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	if b.state == httpc.BreakerStateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+
+	if !failure {
+		if b.state == httpc.BreakerStateHalfOpen {
+			b.setState(httpc.BreakerStateClosed)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	if b.state == httpc.BreakerStateHalfOpen || b.failures >= bOpts.FailureThreshold {
+		b.setState(httpc.BreakerStateOpen)
+		b.openedAt = now
+	}
+}
+
+// cancel - releases probe slot of request which result is unknown (e.g. context is done)
+func (b *breakerSt) cancel(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation && b.state == httpc.BreakerStateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// setState - must be called under lock
+func (b *breakerSt) setState(state string) {
+	b.state = state
+	b.generation++
+}
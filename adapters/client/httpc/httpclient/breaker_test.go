@@ -0,0 +1,129 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	var calls int32
+	var fail int32 = 1
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	host := func() string {
+		u, _ := url.Parse(srv.URL)
+		return u.Host
+	}()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:        http.DefaultClient,
+		Uri:           srv.URL,
+		LogFlags:      httpc.NoLogError,
+		RetryCount:    5,
+		RetryInterval: time.Millisecond,
+		Breaker: &httpc.BreakerOptionsSt{
+			FailureThreshold: 3,
+			CoolDown:         50 * time.Millisecond,
+		},
+	})
+
+	// opens after 3 consecutive failures, retries stop
+	_, err := hc.Send(&httpc.OptionsSt{Uri: "path"})
+	require.ErrorIs(t, err, dopErrs.ServiceNA)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+	require.Equal(t, httpc.BreakerStateOpen, hc.BreakerStates()[host].State)
+
+	// fail fast
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "path", RetryCount: -1})
+	require.ErrorIs(t, err, dopErrs.ServiceNA)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// failed probe opens again
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "path", RetryCount: -1})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.EqualValues(t, 4, atomic.LoadInt32(&calls))
+	require.Equal(t, httpc.BreakerStateOpen, hc.BreakerStates()[host].State)
+
+	// successful probe closes
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&fail, 0)
+
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "path", RetryCount: -1})
+	require.Nil(t, err)
+	require.Equal(t, httpc.BreakerStateClosed, hc.BreakerStates()[host].State)
+	require.Zero(t, hc.BreakerStates()[host].Failures)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := &breakerSt{state: httpc.BreakerStateClosed}
+	bOpts := (&httpc.BreakerOptionsSt{FailureThreshold: 2, CoolDown: time.Second, HalfOpenMaxCalls: 2}).GetMergedWithDefaults()
+
+	now := time.Now()
+
+	allow := func(now time.Time) uint64 {
+		generation, ok := b.allow(bOpts, now)
+		require.True(t, ok)
+		return generation
+	}
+
+	b.done(bOpts, allow(now), true, now)
+	b.done(bOpts, allow(now), false, now) // success resets failures
+	b.done(bOpts, allow(now), true, now)
+
+	lateGeneration := allow(now) // response of it comes after circuit is opened
+
+	b.done(bOpts, allow(now), true, now)
+	require.Equal(t, httpc.BreakerStateOpen, b.state)
+	_, ok := b.allow(bOpts, now.Add(500*time.Millisecond))
+	require.False(t, ok)
+
+	// late failure does not extend cool-down
+	b.done(bOpts, lateGeneration, true, now.Add(500*time.Millisecond))
+	require.Equal(t, now, b.openedAt)
+
+	now = now.Add(time.Second)
+
+	// only HalfOpenMaxCalls probes
+	probe1 := allow(now)
+	probe2 := allow(now)
+	_, ok = b.allow(bOpts, now)
+	require.False(t, ok)
+	require.Equal(t, httpc.BreakerStateHalfOpen, b.state)
+
+	// late success does not close circuit
+	b.done(bOpts, lateGeneration, false, now)
+	require.Equal(t, httpc.BreakerStateHalfOpen, b.state)
+
+	b.cancel(probe1)
+	probe3 := allow(now)
+
+	// failed probe opens circuit, results of other probes are ignored
+	b.done(bOpts, probe2, true, now)
+	require.Equal(t, httpc.BreakerStateOpen, b.state)
+
+	b.done(bOpts, probe3, false, now)
+	require.Equal(t, httpc.BreakerStateOpen, b.state)
+
+	now = now.Add(time.Second)
+
+	b.done(bOpts, allow(now), false, now)
+	require.Equal(t, httpc.BreakerStateClosed, b.state)
+	require.Zero(t, b.failures)
+}
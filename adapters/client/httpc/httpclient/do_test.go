@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/rendau/dop/dopTypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	type reqSt struct {
+		Name string `json:"name"`
+	}
+
+	type repSt struct {
+		Greeting string `json:"greeting"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := reqSt{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		switch r.URL.Path {
+		case "/hello":
+			_ = json.NewEncoder(w).Encode(repSt{Greeting: "hello " + req.Name})
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(dopTypes.ErrRep{ErrorCode: "bad_name", Desc: req.Name})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("oops"))
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		Method:   "POST",
+		LogFlags: httpc.NoLogError,
+	})
+
+	ctx := context.Background()
+
+	rep, resp, err := httpc.Do[reqSt, repSt](ctx, hc, &httpc.OptionsSt{Uri: "hello"}, reqSt{Name: "bob"})
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "hello bob", rep.Greeting)
+
+	rep, _, err = httpc.Do[*reqSt, repSt](ctx, hc, &httpc.OptionsSt{Uri: "hello"}, nil)
+	require.Nil(t, err)
+	require.Equal(t, "hello ", rep.Greeting)
+
+	_, _, err = httpc.DoWithErr[repSt, dopTypes.ErrRep](ctx, hc, &httpc.OptionsSt{Uri: "bad"}, reqSt{Name: "x"})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+
+	var sErr *httpc.StatusErr[dopTypes.ErrRep]
+	require.True(t, errors.As(err, &sErr))
+	require.Equal(t, http.StatusBadRequest, sErr.StatusCode)
+	require.True(t, sErr.BodyDecoded)
+	require.Equal(t, dopTypes.ErrRep{ErrorCode: "bad_name", Desc: "x"}, sErr.Body)
+
+	// body of unexpected format
+	_, _, err = httpc.DoWithErr[repSt, dopTypes.ErrRep](ctx, hc, &httpc.OptionsSt{Uri: "fail"}, nil)
+	require.True(t, errors.As(err, &sErr))
+	require.Equal(t, http.StatusInternalServerError, sErr.StatusCode)
+	require.False(t, sErr.BodyDecoded)
+	require.Equal(t, "oops", string(sErr.BodyRaw))
+
+	var rErr *httpc.StatusErr[json.RawMessage]
+	_, _, err = httpc.Do[any, repSt](ctx, hc, &httpc.OptionsSt{Uri: "bad"}, nil)
+	require.True(t, errors.As(err, &rErr))
+	require.JSONEq(t, `{"error_code":"bad_name"}`, string(rErr.Body))
+}
@@ -8,21 +8,31 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rendau/dop/adapters/client/httpc"
 	"github.com/rendau/dop/adapters/logger"
+	"github.com/rendau/dop/adapters/trace"
 	"github.com/rendau/dop/dopErrs"
 )
 
 type St struct {
 	lg   logger.Lite
 	opts *httpc.OptionsSt
+
+	breakers   map[string]*breakerSt
+	breakersMu sync.Mutex
+
+	limiters   map[string]*limiterSt
+	limitersMu sync.Mutex
 }
 
 func New(lg logger.Lite, opts *httpc.OptionsSt) *St {
 	res := &St{
-		lg: lg,
+		lg:       lg,
+		breakers: map[string]*breakerSt{},
+		limiters: map[string]*limiterSt{},
 	}
 
 	res.SetOptions(opts)
@@ -42,16 +52,49 @@ func (c *St) SetOptions(opts *httpc.OptionsSt) {
 	c.opts = opts
 }
 
+// Send - uses Ctx of options merged with base ones
 func (c *St) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
-	var err error
+	return c.sendMerged(c.opts.GetMergedWith(opts))
+}
+
+// SendCtx - ctx overrides Ctx of options, nil ctx is treated as background one
+func (c *St) SendCtx(ctx context.Context, opts *httpc.OptionsSt) (*httpc.RespSt, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	opts = c.opts.GetMergedWith(opts)
+	opts.Ctx = ctx
+
+	return c.sendMerged(opts)
+}
+
+func (c *St) sendMerged(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
+	var err error
+
+	ctx := opts.Context()
+
+	// propagate request id and trace of incoming request
+	if t, ok := trace.FromContext(ctx); ok {
+		trace.SetHeaders(opts.Headers, t.Child())
+	}
 
 	resp := &httpc.RespSt{ReqOpts: opts, Lg: c.lg}
 
-	// ReqObj
-	if opts.ReqStream == nil {
-		if opts.ReqObj != nil {
+	if opts.ReqStream == nil && opts.ReqStreamFactory == nil {
+		switch {
+		case opts.ReqMultipart != nil:
+			boundary := opts.ReqMultipart.NewBoundary()
+			opts.Headers["Content-Type"] = []string{"multipart/form-data; boundary=" + boundary}
+			opts.ReqStreamFactory = func() (io.Reader, error) {
+				return opts.ReqMultipart.Stream(boundary), nil
+			}
+		case opts.ReqForm != nil:
+			if len(opts.Headers.Values("Content-Type")) == 0 {
+				opts.Headers["Content-Type"] = []string{"application/x-www-form-urlencoded"}
+			}
+			opts.ReqBody = []byte(httpc.FormValues(opts.ReqForm).Encode())
+		case opts.ReqObj != nil:
 			if len(opts.Headers.Values("Content-Type")) == 0 {
 				opts.Headers["Content-Type"] = []string{"application/json"}
 			}
@@ -63,6 +106,15 @@ func (c *St) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
 		}
 	}
 
+	// ReqCompression
+	if opts.ReqCompression != "" {
+		err = c.compressReq(opts)
+		if err != nil {
+			c.lg.Errorw(opts.LogPrefix+"Fail to compress request body", err)
+			return resp, err
+		}
+	}
+
 	// RepObj
 	if opts.RepObj != nil {
 		if len(opts.Headers.Values("Accept")) == 0 {
@@ -74,21 +126,33 @@ func (c *St) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
 		resp.LogInfo("Request: " + opts.Uri)
 	}
 
-	for i := opts.RetryCount; i >= 0; i-- {
-		err = c.send(opts, resp)
-		if err == nil { // if no network error
-			if opts.ReqStream != nil { // not retry for stream
-				break
-			}
-			if resp.StatusCodeSuccess { // not retry for success
-				break
-			}
-			if resp.StatusCode < 500 { // not retry for "< 500" errors
-				break
-			}
+	// waiting for limiter is outside of breaker, so it does not hold half-open probe
+	roundTrip := httpc.ChainInterceptors(opts.Interceptors, c.send)
+	if opts.Breaker != nil {
+		roundTrip = c.withBreaker(roundTrip, opts)
+	}
+	if opts.Limit != nil {
+		roundTrip = c.withLimit(roundTrip, opts)
+	}
+	if opts.Auth != nil {
+		roundTrip = httpc.AuthRoundTrip(opts.Auth, roundTrip)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = roundTrip(opts, resp)
+		if attempt >= opts.RetryCount || !c.needRetry(opts, resp, err) {
+			break
+		}
+		if ctx.Err() != nil { // not retry if context is done
+			break
+		}
+		delay, ok := c.retryDelay(opts, resp, attempt)
+		if !ok {
+			break
 		}
-		if i > 0 {
-			time.Sleep(opts.RetryInterval)
+		if sErr := sleepCtx(ctx, delay); sErr != nil {
+			err = sErr
+			break
 		}
 	}
 
@@ -96,7 +160,15 @@ func (c *St) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
 		err = c.handleRespBadStatusCode(resp)
 		if err == nil {
 			// RepObj
-			if len(resp.BodyRaw) > 0 && resp.ReqOpts.RepObj != nil {
+			if c.decodeFromStream(opts, resp) {
+				err = c.decodeStream(resp)
+				if err != nil {
+					if !resp.ReqOpts.HasLogFlag(httpc.NoLogError) {
+						resp.LogError("Fail to unmarshal body", err)
+					}
+					return resp, err
+				}
+			} else if len(resp.BodyRaw) > 0 && resp.ReqOpts.RepObj != nil {
 				err = json.Unmarshal(resp.BodyRaw, resp.ReqOpts.RepObj)
 				if err != nil {
 					if !resp.ReqOpts.HasLogFlag(httpc.NoLogError) {
@@ -127,20 +199,34 @@ func (c *St) send(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
 	var req *http.Request
 
 	var reqStream io.Reader
-	if opts.ReqStream != nil {
+	if opts.ReqStreamFactory != nil {
+		reqStream, err = opts.ReqStreamFactory()
+		if err != nil {
+			return fmt.Errorf("fail to create request stream: %w", err)
+		}
+	} else if opts.ReqStream != nil {
 		reqStream = opts.ReqStream
 	} else if len(opts.ReqBody) > 0 {
 		reqStream = bytes.NewReader(opts.ReqBody)
 	}
 
+	ctx := opts.Context()
+
+	var cancel context.CancelFunc
 	if opts.Timeout > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
-		defer cancel()
-		req, err = http.NewRequestWithContext(ctx, opts.Method, opts.Uri, reqStream)
-	} else {
-		req, err = http.NewRequest(opts.Method, opts.Uri, reqStream)
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer func() {
+			if cancel != nil {
+				cancel()
+			}
+		}()
 	}
+
+	req, err = http.NewRequestWithContext(ctx, opts.Method, opts.Uri, reqStream)
 	if err != nil {
+		if rc, ok := reqStream.(io.Closer); ok {
+			_ = rc.Close()
+		}
 		return fmt.Errorf("fail to create http-request: %w", err)
 	}
 
@@ -174,12 +260,21 @@ func (c *St) send(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
 
 	resp.Headers = rep.Header
 
-	if opts.RepStream && resp.StatusCodeSuccess {
-		resp.Stream = rep.Body
+	body := rep.Body
+	if opts.MaxResponseBytes > 0 {
+		body = &limitReadCloserSt{ReadCloser: body, left: opts.MaxResponseBytes}
+	}
+
+	if c.decodeFromStream(opts, resp) {
+		resp.Stream = body
+		if cancel != nil { // timeout must cover reading of stream, so cancel on close
+			resp.Stream = &cancelOnCloseSt{ReadCloser: body, cancel: cancel}
+			cancel = nil
+		}
 	} else {
-		defer rep.Body.Close()
+		defer body.Close()
 
-		resp.BodyRaw, err = io.ReadAll(rep.Body)
+		resp.BodyRaw, err = io.ReadAll(body)
 		if err != nil {
 			return fmt.Errorf("fail to read response body: %w", err)
 		}
@@ -188,6 +283,67 @@ func (c *St) send(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
 	return nil
 }
 
+// decodeFromStream - response body is left in resp.Stream: requested as stream or for decoding of RepObj
+func (c *St) decodeFromStream(opts *httpc.OptionsSt, resp *httpc.RespSt) bool {
+	return resp.StatusCodeSuccess && (opts.RepStream || (opts.RepObjStream && opts.RepObj != nil))
+}
+
+// decodeStream - decodes RepObj from resp.Stream and closes it
+func (c *St) decodeStream(resp *httpc.RespSt) error {
+	if resp.ReqOpts.RepStream || resp.Stream == nil {
+		return nil
+	}
+
+	defer func() {
+		_ = resp.Stream.Close()
+		resp.Stream = nil
+	}()
+
+	err := json.NewDecoder(resp.Stream).Decode(resp.ReqOpts.RepObj)
+	if err == io.EOF { // empty body
+		return nil
+	}
+
+	return err
+}
+
+func (c *St) compressReq(opts *httpc.OptionsSt) error {
+	encoding := opts.ReqCompression
+
+	switch {
+	case opts.ReqStreamFactory != nil:
+		factory := opts.ReqStreamFactory
+		opts.ReqStreamFactory = func() (io.Reader, error) {
+			r, err := factory()
+			if err != nil {
+				return nil, err
+			}
+			return httpc.CompressStream(r, encoding)
+		}
+	case opts.ReqStream != nil:
+		stream, err := httpc.CompressStream(opts.ReqStream, encoding)
+		if err != nil {
+			return err
+		}
+		opts.ReqStream = stream
+	case len(opts.ReqBody) > 0:
+		// ReqBody is kept uncompressed for logs
+		body, err := httpc.Compress(opts.ReqBody, encoding)
+		if err != nil {
+			return err
+		}
+		opts.ReqStreamFactory = func() (io.Reader, error) {
+			return bytes.NewReader(body), nil
+		}
+	default:
+		return nil
+	}
+
+	opts.Headers["Content-Encoding"] = []string{encoding}
+
+	return nil
+}
+
 func (c *St) handleRespBadStatusCode(resp *httpc.RespSt) error {
 	if resp.StatusCode > 0 && !resp.StatusCodeSuccess {
 		if sObj, ok := resp.ReqOpts.StatusRepObj[resp.StatusCode]; ok {
@@ -213,3 +369,57 @@ func (c *St) handleRespBadStatusCode(resp *httpc.RespSt) error {
 
 	return nil
 }
+
+// sleepCtx - sleeps for d, returns context error if it is done earlier
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitReadCloserSt - returns ErrResponseTooLarge if body is larger than left bytes
+type limitReadCloserSt struct {
+	io.ReadCloser
+	left int64
+}
+
+func (o *limitReadCloserSt) Read(p []byte) (int, error) {
+	if o.left < 0 {
+		return 0, httpc.ErrResponseTooLarge
+	}
+
+	if int64(len(p)) > o.left+1 {
+		p = p[:o.left+1]
+	}
+
+	n, err := o.ReadCloser.Read(p)
+	if int64(n) > o.left {
+		n = int(o.left)
+		o.left = -1
+		return n, httpc.ErrResponseTooLarge
+	}
+
+	o.left -= int64(n)
+
+	return n, err
+}
+
+type cancelOnCloseSt struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (o *cancelOnCloseSt) Close() error {
+	defer o.cancel()
+	return o.ReadCloser.Close()
+}
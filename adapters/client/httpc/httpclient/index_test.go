@@ -0,0 +1,290 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/adapters/logger/zap"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/stretchr/testify/require"
+)
+
+var lg = zap.New("info", true)
+
+func TestInterceptors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	calls := make([]string, 0)
+
+	trace := func(name string) httpc.Interceptor {
+		return func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+			calls = append(calls, name+":before")
+			err := next(opts, resp)
+			calls = append(calls, name+":after")
+			return err
+		}
+	}
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    srv.URL,
+		Interceptors: []httpc.Interceptor{
+			trace("base"),
+			func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+				opts.Headers.Set("Authorization", "Bearer token")
+				return next(opts, resp)
+			},
+		},
+	})
+
+	var statusCode int
+
+	resp, err := hc.Send(&httpc.OptionsSt{
+		Uri: "path",
+		Interceptors: []httpc.Interceptor{
+			trace("call"),
+			func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+				err := next(opts, resp)
+				statusCode = resp.StatusCode
+				return err
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, "Bearer token", resp.Headers.Get("X-Token"))
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Equal(t, []string{"base:before", "call:before", "call:after", "base:after"}, calls)
+
+	// short circuit
+	_, err = hc.Send(&httpc.OptionsSt{
+		Uri: "path",
+		Interceptors: []httpc.Interceptor{
+			func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+				resp.StatusCode = http.StatusTeapot
+				return nil
+			},
+		},
+		LogFlags: httpc.NoLogError,
+	})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+}
+
+func TestSendCtx(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		case "/e500":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte("stream-data"))
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		LogFlags: httpc.NoLogError,
+	})
+
+	// deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := hc.SendCtx(ctx, &httpc.OptionsSt{Uri: "slow"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// retry sleep is interrupted
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	atomic.StoreInt32(&calls, 0)
+	start = time.Now()
+	_, err = hc.SendCtx(ctx, &httpc.OptionsSt{
+		Uri:           "e500",
+		RetryCount:    3,
+		RetryInterval: time.Second,
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// ctx from options
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = hc.Send(&httpc.OptionsSt{Ctx: ctx, Uri: "e500", RetryCount: 3})
+	require.ErrorIs(t, err, context.Canceled)
+	require.EqualValues(t, 0, atomic.LoadInt32(&calls))
+
+	// ctx from base options
+	hcCtx := New(lg, &httpc.OptionsSt{
+		Ctx:      ctx,
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		LogFlags: httpc.NoLogError,
+	})
+
+	_, err = hcCtx.Send(&httpc.OptionsSt{Uri: "e500"})
+	require.ErrorIs(t, err, context.Canceled)
+	require.EqualValues(t, 0, atomic.LoadInt32(&calls))
+
+	// nil ctx
+	_, err = hc.SendCtx(nil, &httpc.OptionsSt{Uri: "e500", RetryCount: 1})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// timeout covers reading of stream
+	resp, err := hc.Send(&httpc.OptionsSt{
+		Uri:       "stream",
+		RepStream: true,
+		Timeout:   time.Second,
+	})
+	require.Nil(t, err)
+	data, err := io.ReadAll(resp.Stream)
+	require.Nil(t, err)
+	require.Nil(t, resp.Stream.Close())
+	require.Equal(t, "stream-data", string(data))
+}
+
+func TestReqForm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		_, _ = w.Write([]byte(r.PostForm.Encode()))
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    srv.URL,
+		Method: "POST",
+	})
+
+	resp, err := hc.Send(&httpc.OptionsSt{ReqForm: url.Values{"a": {"1", "2"}, "b": {"x y"}}})
+	require.Nil(t, err)
+	require.Equal(t, "application/x-www-form-urlencoded", resp.Headers.Get("X-Content-Type"))
+	require.Equal(t, "a=1&a=2&b=x+y", string(resp.BodyRaw))
+
+	resp, err = hc.Send(&httpc.OptionsSt{ReqForm: &struct {
+		Name string `form:"name"`
+		Ids  []int  `form:"ids"`
+	}{Name: "n", Ids: []int{1, 2}}})
+	require.Nil(t, err)
+	require.Equal(t, "ids=1&ids=2&name=n", string(resp.BodyRaw))
+}
+
+func TestReqMultipart(t *testing.T) {
+	var calls int32
+
+	type partSt struct {
+		Name        string
+		FileName    string
+		ContentType string
+		Data        string
+	}
+
+	parts := make(chan []partSt, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+
+		mr, err := r.MultipartReader()
+		if err != nil || r.ContentLength != -1 { // must be streamed
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		res := make([]partSt, 0)
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(p)
+			res = append(res, partSt{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(data)})
+		}
+		parts <- res
+
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:        http.DefaultClient,
+		Uri:           srv.URL,
+		Method:        "POST",
+		LogFlags:      httpc.NoLogError,
+		RetryCount:    1,
+		RetryInterval: time.Millisecond,
+	})
+
+	// retried with reopened files
+	_, err := hc.Send(&httpc.OptionsSt{
+		Headers: http.Header{"Idempotency-Key": {"k1"}},
+		ReqMultipart: &httpc.MultipartSt{
+			Fields: url.Values{"title": {"doc"}},
+			Files: []*httpc.MultipartFileSt{{
+				Field:       "file",
+				FileName:    "a.txt",
+				ContentType: "text/plain",
+				Open: func() (io.Reader, error) {
+					return io.NopCloser(strings.NewReader("content")), nil
+				},
+			}},
+		},
+	})
+	require.Nil(t, err)
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	want := []partSt{
+		{Name: "title", Data: "doc"},
+		{Name: "file", FileName: "a.txt", ContentType: "text/plain", Data: "content"},
+	}
+	require.Equal(t, want, <-parts)
+	require.Equal(t, want, <-parts)
+
+	// not retried with reader
+	atomic.StoreInt32(&calls, 0)
+
+	_, err = hc.Send(&httpc.OptionsSt{
+		ReqMultipart: &httpc.MultipartSt{
+			Files: []*httpc.MultipartFileSt{{
+				Field:    "file",
+				FileName: "b.bin",
+				Reader:   strings.NewReader("bin"),
+			}},
+		},
+	})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	require.Equal(t, []partSt{{Name: "file", FileName: "b.bin", ContentType: "application/octet-stream", Data: "bin"}}, <-parts)
+}
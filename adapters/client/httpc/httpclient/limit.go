@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+)
+
+type limitsSt struct {
+	rate        float64
+	burst       int
+	maxInFlight int
+}
+
+type limiterSt struct {
+	limits limitsSt
+
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+
+	sem chan struct{}
+}
+
+func newLimiter(lOpts *httpc.LimitOptionsSt, limits limitsSt) *limiterSt {
+	res := &limiterSt{
+		limits: limits,
+		rate:   lOpts.Rate,
+		burst:  float64(lOpts.Burst),
+		tokens: float64(lOpts.Burst),
+		last:   time.Now(),
+	}
+
+	if lOpts.MaxInFlight > 0 {
+		res.sem = make(chan struct{}, lOpts.MaxInFlight)
+	}
+
+	return res
+}
+
+func (c *St) getLimiter(lOpts *httpc.LimitOptionsSt, opts *httpc.OptionsSt) *limiterSt {
+	key := ""
+	if lOpts.Key != nil {
+		key = lOpts.Key(opts)
+	}
+
+	limits := limitsSt{
+		rate:        lOpts.Rate,
+		burst:       lOpts.Burst,
+		maxInFlight: lOpts.MaxInFlight,
+	}
+
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+
+	// one limiter per key, it is replaced if limits are changed (slots of old one are released to it)
+	l := c.limiters[key]
+	if l == nil || l.limits != limits {
+		l = newLimiter(lOpts, limits)
+		c.limiters[key] = l
+	}
+
+	return l
+}
+
+// withLimit - wraps round trip, waits for rate limit and free slot of MaxInFlight
+func (c *St) withLimit(rt httpc.RoundTripFn, opts *httpc.OptionsSt) httpc.RoundTripFn {
+	lOpts := opts.Limit.GetMergedWithDefaults()
+	if lOpts.Rate <= 0 && lOpts.MaxInFlight <= 0 {
+		return rt
+	}
+
+	l := c.getLimiter(lOpts, opts)
+
+	return func(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
+		ctx := opts.Context()
+
+		if err := l.wait(ctx); err != nil {
+			resp.Reset()
+			return err
+		}
+
+		if err := l.acquire(ctx); err != nil {
+			resp.Reset()
+			return err
+		}
+
+		err := rt(opts, resp)
+
+		if err == nil && resp.Stream != nil { // slot is busy until stream is read
+			resp.Stream = &releaseOnCloseSt{ReadCloser: resp.Stream, release: l.release}
+			return nil
+		}
+
+		l.release()
+
+		return err
+	}
+}
+
+// wait - reserves token of bucket, reservation is returned if context is done earlier
+func (l *limiterSt) wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	err := ctx.Err()
+	if err == nil {
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+			err = httpc.ErrRateLimited
+		} else {
+			err = sleepCtx(ctx, delay)
+		}
+	}
+
+	if err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+	}
+
+	return err
+}
+
+func (l *limiterSt) acquire(ctx context.Context) error {
+	if l.sem == nil {
+		return nil
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiterSt) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+type releaseOnCloseSt struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (o *releaseOnCloseSt) Close() error {
+	defer o.once.Do(o.release)
+	return o.ReadCloser.Close()
+}
@@ -0,0 +1,246 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    srv.URL,
+		Limit:  &httpc.LimitOptionsSt{Rate: 20, Burst: 2},
+	})
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := hc.Send(&httpc.OptionsSt{})
+		require.Nil(t, err)
+	}
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 180*time.Millisecond) // 2 at once, 4 by 50ms
+	require.Less(t, elapsed, time.Second)
+
+	// per call override
+	start = time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := hc.Send(&httpc.OptionsSt{Limit: &httpc.LimitOptionsSt{}})
+		require.Nil(t, err)
+	}
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// waiting is interrupted by context
+	hc.GetOptions().Limit = &httpc.LimitOptionsSt{Rate: 1}
+
+	_, err := hc.Send(&httpc.OptionsSt{})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	_, err = hc.SendCtx(ctx, &httpc.OptionsSt{
+		LogFlags:   httpc.NoLogError,
+		RetryCount: 3,
+		Breaker:    &httpc.BreakerOptionsSt{FailureThreshold: 1},
+	})
+	require.ErrorIs(t, err, httpc.ErrRateLimited)
+	require.Less(t, time.Since(start), 40*time.Millisecond) // fails without waiting of deadline and retries
+
+	// not a failure of upstream
+	for _, state := range hc.BreakerStates() {
+		require.Equal(t, httpc.BreakerStateClosed, state.State)
+		require.Zero(t, state.Failures)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	_, err = hc.SendCtx(ctx, &httpc.OptionsSt{LogFlags: httpc.NoLogError})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLimitMaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(30 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    srv.URL,
+		Limit:  &httpc.LimitOptionsSt{MaxInFlight: 2},
+	})
+
+	errs := make(chan error, 8)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := hc.Send(&httpc.OptionsSt{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		require.Nil(t, <-errs)
+	}
+
+	require.EqualValues(t, 2, atomic.LoadInt32(&maxInFlight))
+
+	// separate limiter by key
+	atomic.StoreInt32(&maxInFlight, 0)
+
+	limit := &httpc.LimitOptionsSt{
+		MaxInFlight: 1,
+		Key:         func(opts *httpc.OptionsSt) string { return opts.Uri },
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := hc.Send(&httpc.OptionsSt{Uri: []string{"a", "b"}[i%2], Limit: limit})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		require.Nil(t, <-errs)
+	}
+
+	require.EqualValues(t, 2, atomic.LoadInt32(&maxInFlight))
+}
+
+func TestLimitStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		LogFlags: httpc.NoLogError,
+		Limit:    &httpc.LimitOptionsSt{MaxInFlight: 1},
+	})
+
+	resp, err := hc.Send(&httpc.OptionsSt{RepStream: true})
+	require.Nil(t, err)
+
+	// slot is busy while stream is not closed
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	_, err = hc.SendCtx(ctx, &httpc.OptionsSt{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	data, err := io.ReadAll(resp.Stream)
+	require.Nil(t, err)
+	require.Equal(t, "data", string(data))
+	require.Nil(t, resp.Stream.Close())
+	require.Nil(t, resp.Stream.Close()) // released once
+
+	_, err = hc.Send(&httpc.OptionsSt{})
+	require.Nil(t, err)
+
+	// decoded stream releases slot
+	rep := map[string]any{}
+
+	_, err = hc.Send(&httpc.OptionsSt{RepObjStream: true, RepObj: &rep})
+	require.NotNil(t, err) // not json
+
+	_, err = hc.Send(&httpc.OptionsSt{})
+	require.Nil(t, err)
+}
+
+func TestLimitOutsideBreaker(t *testing.T) {
+	var fail int32 = 1
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		LogFlags: httpc.NoLogError,
+		Limit:    &httpc.LimitOptionsSt{Rate: 5},
+		Breaker:  &httpc.BreakerOptionsSt{FailureThreshold: 1, CoolDown: 10 * time.Millisecond},
+	})
+
+	_, err := hc.Send(&httpc.OptionsSt{})
+	require.NotNil(t, err)
+
+	atomic.StoreInt32(&fail, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := hc.Send(&httpc.OptionsSt{})
+		done <- err
+	}()
+
+	// request waits for limiter, half-open probe is not taken yet
+	time.Sleep(50 * time.Millisecond)
+	for _, state := range hc.BreakerStates() {
+		require.Equal(t, httpc.BreakerStateOpen, state.State)
+	}
+
+	require.Nil(t, <-done)
+	for _, state := range hc.BreakerStates() {
+		require.Equal(t, httpc.BreakerStateClosed, state.State)
+	}
+}
+
+func TestLimitKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    srv.URL,
+	})
+
+	// limiter is kept per key, not per limits
+	for i := 1; i <= 5; i++ {
+		_, err := hc.Send(&httpc.OptionsSt{Limit: &httpc.LimitOptionsSt{Rate: float64(1000 * i)}})
+		require.Nil(t, err)
+	}
+	require.Len(t, hc.limiters, 1)
+
+	_, err := hc.Send(&httpc.OptionsSt{Limit: &httpc.LimitOptionsSt{Rate: 1000, Key: func(opts *httpc.OptionsSt) string { return "other" }}})
+	require.Nil(t, err)
+	require.Len(t, hc.limiters, 2)
+}
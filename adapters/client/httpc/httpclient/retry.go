@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopErrs"
+)
+
+func (c *St) needRetry(opts *httpc.OptionsSt, resp *httpc.RespSt, err error) bool {
+	if errors.Is(err, dopErrs.ServiceNA) { // circuit is open
+		return false
+	}
+	if errors.Is(err, httpc.ErrResponseTooLarge) || errors.Is(err, httpc.ErrRateLimited) {
+		return false
+	}
+
+	if !opts.ReqBodyReusable() { // stream or some file is consumed
+		return false
+	}
+
+	if (opts.RetryPolicy == nil || !opts.RetryPolicy.RetryNonIdempotent) && !httpc.IsIdempotent(opts) {
+		return false
+	}
+
+	if opts.RetryPolicy == nil {
+		return err != nil || (!resp.StatusCodeSuccess && resp.StatusCode >= http.StatusInternalServerError)
+	}
+
+	if opts.RetryPolicy.Predicate != nil {
+		return opts.RetryPolicy.Predicate(resp, err)
+	}
+
+	return httpc.DefaultRetryPredicate(resp, err)
+}
+
+// retryDelay - returns false if server asks to wait longer than MaxDelay
+func (c *St) retryDelay(opts *httpc.OptionsSt, resp *httpc.RespSt, attempt int) (time.Duration, bool) {
+	if opts.RetryPolicy == nil {
+		return opts.RetryInterval, true
+	}
+
+	delay := opts.RetryPolicy.Delay(opts.RetryInterval, attempt)
+
+	if !opts.RetryPolicy.NoRetryAfter &&
+		(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if ra, ok := httpc.ParseRetryAfter(resp.Headers, time.Now()); ok {
+			if opts.RetryPolicy.MaxDelay > 0 && ra > opts.RetryPolicy.MaxDelay {
+				return 0, false
+			}
+			if ra > delay {
+				delay = ra
+			}
+		}
+	}
+
+	return delay, true
+}
@@ -0,0 +1,194 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	var failCount int32
+
+	bodies := make(chan string, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+
+		if atomic.AddInt32(&failCount, -1) >= 0 {
+			switch r.URL.Path {
+			case "/e429":
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+			case "/e404":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:        http.DefaultClient,
+		Uri:           srv.URL,
+		LogFlags:      httpc.NoLogError,
+		RetryCount:    3,
+		RetryInterval: 5 * time.Millisecond,
+		RetryPolicy:   &httpc.RetryPolicySt{MaxDelay: time.Second, Jitter: 0.5},
+	})
+
+	cases := []struct {
+		name      string
+		opts      *httpc.OptionsSt
+		failCount int32
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "get", opts: &httpc.OptionsSt{Uri: "e500"}, failCount: 3, wantCalls: 4},
+		{name: "get exhausted", opts: &httpc.OptionsSt{Uri: "e500"}, failCount: 10, wantCalls: 4, wantErr: true},
+		{name: "post", opts: &httpc.OptionsSt{Uri: "e500", Method: "POST"}, failCount: 1, wantCalls: 1, wantErr: true},
+		{
+			name:      "post allowed",
+			opts:      &httpc.OptionsSt{Uri: "e500", Method: "POST", RetryPolicy: &httpc.RetryPolicySt{RetryNonIdempotent: true}},
+			failCount: 1,
+			wantCalls: 2,
+		},
+		{
+			name:      "post with idempotency key",
+			opts:      &httpc.OptionsSt{Uri: "e500", Method: "POST", Headers: http.Header{"Idempotency-Key": {"k1"}}},
+			failCount: 1,
+			wantCalls: 2,
+		},
+		{name: "retry-after above max delay", opts: &httpc.OptionsSt{Uri: "e429"}, failCount: 1, wantCalls: 1, wantErr: true},
+		{name: "not retryable status", opts: &httpc.OptionsSt{Uri: "e404"}, failCount: 1, wantCalls: 1, wantErr: true},
+		{
+			name: "predicate",
+			opts: &httpc.OptionsSt{Uri: "e404", RetryPolicy: &httpc.RetryPolicySt{
+				Predicate: func(resp *httpc.RespSt, err error) bool {
+					return err == nil && resp.StatusCode == http.StatusNotFound
+				},
+			}},
+			failCount: 1,
+			wantCalls: 2,
+		},
+		{
+			name: "stream without factory",
+			opts: &httpc.OptionsSt{
+				Uri:         "e500",
+				Method:      "PUT",
+				ReqStream:   strings.NewReader("data"),
+				RetryPolicy: &httpc.RetryPolicySt{},
+			},
+			failCount: 1,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name: "stream factory",
+			opts: &httpc.OptionsSt{
+				Uri:    "e500",
+				Method: "PUT",
+				ReqStreamFactory: func() (io.Reader, error) {
+					return strings.NewReader("data"), nil
+				},
+			},
+			failCount: 2,
+			wantCalls: 3,
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			atomic.StoreInt32(&failCount, cs.failCount)
+
+			_, err := hc.Send(cs.opts)
+			if cs.wantErr {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, cs.wantCalls, atomic.LoadInt32(&calls))
+
+			for i := int32(0); i < cs.wantCalls; i++ {
+				body := <-bodies
+				if cs.opts.ReqStreamFactory != nil {
+					require.Equal(t, "data", body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryWithoutPolicy(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	hc := New(lg, &httpc.OptionsSt{
+		Client:        http.DefaultClient,
+		Uri:           srv.URL,
+		LogFlags:      httpc.NoLogError,
+		RetryCount:    2,
+		RetryInterval: time.Millisecond,
+	})
+
+	_, err := hc.Send(&httpc.OptionsSt{Method: "POST", ReqBody: []byte("data")})
+	require.NotNil(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = hc.Send(&httpc.OptionsSt{Method: "POST", Headers: http.Header{"Idempotency-Key": {"k1"}}, ReqBody: []byte("data")})
+	require.NotNil(t, err)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = hc.Send(&httpc.OptionsSt{Method: "GET"})
+	require.NotNil(t, err)
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestRetryDelay(t *testing.T) {
+	c := New(lg, &httpc.OptionsSt{})
+
+	opts := &httpc.OptionsSt{
+		RetryInterval: 100 * time.Millisecond,
+		RetryPolicy:   &httpc.RetryPolicySt{MaxDelay: 10 * time.Second},
+	}
+
+	delay, ok := c.retryDelay(opts, &httpc.RespSt{StatusCode: 500}, 2)
+	require.True(t, ok)
+	require.Equal(t, 400*time.Millisecond, delay)
+
+	delay, ok = c.retryDelay(opts, &httpc.RespSt{StatusCode: 503, Headers: http.Header{"Retry-After": {"2"}}}, 0)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, delay)
+
+	_, ok = c.retryDelay(opts, &httpc.RespSt{StatusCode: 429, Headers: http.Header{"Retry-After": {"20"}}}, 0)
+	require.False(t, ok)
+
+	delay, ok = c.retryDelay(opts, &httpc.RespSt{StatusCode: 500, Headers: http.Header{"Retry-After": {"2"}}}, 0)
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, delay)
+
+	// legacy
+	delay, ok = c.retryDelay(&httpc.OptionsSt{RetryInterval: time.Second}, &httpc.RespSt{}, 5)
+	require.True(t, ok)
+	require.Equal(t, time.Second, delay)
+}
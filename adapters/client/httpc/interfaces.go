@@ -1,7 +1,20 @@
 package httpc
 
+import (
+	"context"
+)
+
 type HttpC interface {
 	GetOptions() *OptionsSt
 	SetOptions(opts *OptionsSt)
 	Send(opts *OptionsSt) (*RespSt, error)
+	SendCtx(ctx context.Context, opts *OptionsSt) (*RespSt, error)
+}
+
+// AuthProvider - source of bearer tokens for OptionsSt.Auth, e.g. oauth2.St
+type AuthProvider interface {
+	// Token - returns valid token, cached while it is not expired
+	Token(ctx context.Context) (string, error)
+	// Refresh - returns new token instead of rejected one (request got 401)
+	Refresh(ctx context.Context, rejected string) (string, error)
 }
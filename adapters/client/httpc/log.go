@@ -0,0 +1,128 @@
+package httpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func redactHeaderValues(headers http.Header, names []string) http.Header {
+	result := headers.Clone()
+
+	for _, name := range names {
+		if vs := result.Values(name); len(vs) > 0 {
+			result[http.CanonicalHeaderKey(name)] = []string{logRedactedValue}
+		}
+	}
+
+	return result
+}
+
+func redactValues(values url.Values, fields []string) url.Values {
+	result := url.Values{}
+
+	for k, vs := range values {
+		if containsFold(fields, k) {
+			result[k] = []string{logRedactedValue}
+		} else {
+			result[k] = vs
+		}
+	}
+
+	return result
+}
+
+// redactBody - hides fields in json and url-encoded bodies, other bodies are returned as is
+func redactBody(body []byte, contentType string, fields []string) []byte {
+	if len(body) == 0 || len(fields) == 0 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		for k := range values {
+			if containsFold(fields, k) {
+				return []byte(redactValues(values, fields).Encode())
+			}
+		}
+		return body
+	}
+
+	if !json.Valid(body) {
+		return body
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var obj any
+
+	if dec.Decode(&obj) != nil || !redactJson(obj, fields) {
+		return body
+	}
+
+	result, err := json.Marshal(obj)
+	if err != nil {
+		return body
+	}
+
+	return result
+}
+
+// redactJson - returns true if something is redacted
+func redactJson(obj any, fields []string) bool {
+	redacted := false
+
+	switch v := obj.(type) {
+	case map[string]any:
+		for k, fv := range v {
+			if containsFold(fields, k) {
+				v[k] = logRedactedValue
+				redacted = true
+			} else if redactJson(fv, fields) {
+				redacted = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if redactJson(item, fields) {
+				redacted = true
+			}
+		}
+	}
+
+	return redacted
+}
+
+func truncateLogBody(body []byte, maxSize int) string {
+	if maxSize < 0 || len(body) <= maxSize {
+		return string(body)
+	}
+
+	size := maxSize
+	for size > 0 && !utf8.RuneStart(body[size]) {
+		size--
+	}
+
+	return string(body[:size]) + "...(truncated, " + strconv.Itoa(len(body)) + " bytes)"
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package httpc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillLogArgs(t *testing.T) {
+	logArgs := func(resp *RespSt) map[string]any {
+		args := resp.fillLogArgs("k", "v")
+		res := map[string]any{}
+		for i := 0; i < len(args); i += 2 {
+			res[args[i].(string)] = args[i+1]
+		}
+		return res
+	}
+
+	resp := &RespSt{
+		ReqOpts: &OptionsSt{
+			Method:  "POST",
+			Uri:     "http://host/login",
+			Params:  url.Values{"Token": {"t1"}, "page": {"2"}},
+			Headers: http.Header{"Authorization": {"Bearer x"}, "Content-Type": {"application/json"}},
+			ReqBody: []byte(`{"user":{"login":"u","Password":"p"},"items":[{"secret":1}]}`),
+		},
+		StatusCode: 200,
+		Headers:    http.Header{"Set-Cookie": {"sid=1"}, "Content-Type": {"application/x-www-form-urlencoded"}},
+		BodyRaw:    []byte("access_token=abc&scope=all"),
+	}
+
+	args := logArgs(resp)
+	require.Equal(t, "v", args["k"])
+	require.Equal(t, "Token=%2A%2A%2A&page=2", args["params"])
+	require.Equal(t, []string{"***"}, args["req_headers"].(http.Header)["Authorization"])
+	require.Equal(t, []string{"Bearer x"}, resp.ReqOpts.Headers["Authorization"])
+	require.JSONEq(t, `{"user":{"login":"u","Password":"***"},"items":[{"secret":"***"}]}`, args["req_body"].(string))
+	require.Equal(t, []string{"***"}, args["rep_headers"].(http.Header)["Set-Cookie"])
+	require.Equal(t, "access_token=%2A%2A%2A&scope=all", args["rep_body"])
+
+	// custom lists
+	resp.ReqOpts.LogRedactHeaders = []string{}
+	resp.ReqOpts.LogRedactFields = []string{"login"}
+	args = logArgs(resp)
+	require.Equal(t, []string{"Bearer x"}, args["req_headers"].(http.Header)["Authorization"])
+	require.JSONEq(t, `{"user":{"login":"***","Password":"p"},"items":[{"secret":1}]}`, args["req_body"].(string))
+
+	// not json body is kept, big numbers are not changed
+	resp.ReqOpts.ReqBody = []byte(`{"id":12345678901234567890,"token":"x"`)
+	require.Equal(t, string(resp.ReqOpts.ReqBody), logArgs(resp)["req_body"])
+	resp.ReqOpts.ReqBody = []byte(`{"id":12345678901234567890,"login":"x"}`)
+	require.Equal(t, `{"id":12345678901234567890,"login":"***"}`, logArgs(resp)["req_body"])
+
+	// truncation
+	resp.BodyRaw = []byte(strings.Repeat("a", 5000))
+	require.Equal(t, strings.Repeat("a", 4096)+"...(truncated, 5000 bytes)", logArgs(resp)["rep_body"])
+
+	resp.ReqOpts.LogBodyMaxSize = 3
+	resp.BodyRaw = []byte("абв")
+	require.Equal(t, "а...(truncated, 6 bytes)", logArgs(resp)["rep_body"])
+
+	resp.ReqOpts.LogBodyMaxSize = -1
+	resp.BodyRaw = []byte(strings.Repeat("a", 5000))
+	require.Len(t, logArgs(resp)["rep_body"], 5000)
+}
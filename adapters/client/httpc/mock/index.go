@@ -1,11 +1,16 @@
 package mock
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/rendau/dop/adapters/client/httpc"
 	"github.com/rendau/dop/adapters/logger"
+	"github.com/rendau/dop/adapters/trace"
 	"github.com/rendau/dop/dopErrs"
 )
 
@@ -14,25 +19,33 @@ const (
 )
 
 type St struct {
-	lg logger.Lite
+	lg   logger.Lite
+	opts *httpc.OptionsSt
 
-	requests  []*httpc.OptionsSt
-	responses map[string]ResponseSt
-	mu        sync.Mutex
+	requests     []*httpc.OptionsSt
+	responses    map[string]ResponseSt
+	expectations []*ExpectationSt
+	mu           sync.Mutex
 }
 
 type ResponseSt struct {
 	RespObj any
 	Resp    *httpc.RespSt
-	Err     error
+	// Err - returned as network error, request is retried if RetryCount is set (only for responses of Expect)
+	Err error
+
+	// strict - set for responses of Expect
+	strict bool
 }
 
 func New(lg logger.Lite) *St {
 	return &St{
-		lg: lg,
+		lg:   lg,
+		opts: &httpc.OptionsSt{},
 
-		requests:  []*httpc.OptionsSt{},
-		responses: map[string]ResponseSt{},
+		requests:     []*httpc.OptionsSt{},
+		responses:    map[string]ResponseSt{},
+		expectations: []*ExpectationSt{},
 	}
 }
 
@@ -50,6 +63,73 @@ func (c *St) SetResponse(path string, response ResponseSt) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.responses[path] = c.prepareResponse(response)
+}
+
+// Expect - registers responses for requests matched by m, they are returned in order, the last one repeats.
+// Expectations are checked in order of registration before responses set by SetResponse.
+// Unlike SetResponse, responses are returned as httpclient does: Err and non-2xx status are errors, retried by RetryCount
+func (c *St) Expect(m MatcherSt, responses ...ResponseSt) *ExpectationSt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(responses) == 0 {
+		responses = []ResponseSt{{}}
+	}
+
+	e := &ExpectationSt{
+		matcher:   m,
+		responses: make([]ResponseSt, len(responses)),
+		times:     -1,
+	}
+
+	for i, r := range responses {
+		e.responses[i] = c.prepareResponse(r)
+		e.responses[i].strict = true
+	}
+
+	c.expectations = append(c.expectations, e)
+
+	return e
+}
+
+// Verify - returns error if some expectation is called not expected number of times
+func (c *St) Verify() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]string, 0)
+
+	for _, e := range c.expectations {
+		if err := e.verify(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("httpc-mock: " + strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// CallCount - count of requests (including retries) matched by m
+func (c *St) CallCount(m MatcherSt) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := 0
+
+	for _, req := range c.requests {
+		if m.Match(req) {
+			result++
+		}
+	}
+
+	return result
+}
+
+func (c *St) prepareResponse(response ResponseSt) ResponseSt {
 	if response.Resp == nil {
 		response.Resp = &httpc.RespSt{}
 	}
@@ -65,52 +145,164 @@ func (c *St) SetResponse(path string, response ResponseSt) {
 		}
 	}
 
-	c.responses[path] = response
+	return response
 }
 
+// SetOptions - only Interceptors (they wrap interceptors of every request), Auth, RetryCount and Ctx of opts are used
 func (c *St) SetOptions(opts *httpc.OptionsSt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.opts = opts
 }
 
 func (c *St) GetOptions() *httpc.OptionsSt {
-	return &httpc.OptionsSt{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.opts
 }
 
+// Send - uses Ctx of options, or Ctx of base options if it is not set
 func (c *St) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	ctx := opts.Ctx
+	if ctx == nil {
+		c.mu.Lock()
+		ctx = c.opts.Ctx
+		c.mu.Unlock()
+	}
 
+	return c.SendCtx(ctx, opts)
+}
+
+// SendCtx - ctx overrides Ctx of options, nil ctx is treated as background one
+func (c *St) SendCtx(ctx context.Context, opts *httpc.OptionsSt) (*httpc.RespSt, error) {
 	var err error
 
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	opts.Ctx = ctx
+
 	if opts.ReqObj != nil {
 		opts.ReqBody, err = json.Marshal(opts.ReqObj)
 		if err != nil {
 			c.lg.Errorw("Fail to marshal json", err)
 			return nil, err
 		}
+	} else if opts.ReqForm != nil {
+		opts.ReqBody = []byte(httpc.FormValues(opts.ReqForm).Encode())
 	}
 
-	c.requests = append(c.requests, opts)
+	c.mu.Lock()
+	interceptors := append(append([]httpc.Interceptor{}, c.opts.Interceptors...), opts.Interceptors...)
+	auth := opts.Auth
+	if auth == nil {
+		auth = c.opts.Auth
+	}
+	retryCount := opts.RetryCount
+	if retryCount == 0 {
+		retryCount = c.opts.RetryCount
+	}
+	c.mu.Unlock()
 
-	response, ok := c.responses[opts.Uri]
-	if !ok {
-		c.lg.Infow("Httpc-mock, path not found", "path", opts.Uri)
-		return &httpc.RespSt{
-			Lg:      c.lg,
-			ReqOpts: opts,
-		}, ErrPageNotFound
+	if opts.Headers == nil {
+		opts.Headers = http.Header{}
+	}
+
+	if t, ok := trace.FromContext(ctx); ok {
+		trace.SetHeaders(opts.Headers, t.Child())
 	}
 
-	response.Resp.ReqOpts = opts
+	resp := &httpc.RespSt{
+		Lg:      c.lg,
+		ReqOpts: opts,
+	}
+
+	strict := false
+
+	roundTrip := httpc.ChainInterceptors(interceptors, func(opts *httpc.OptionsSt, resp *httpc.RespSt) error {
+		var rtErr error
+		strict, rtErr = c.roundTrip(opts, resp)
+		return rtErr
+	})
+	if auth != nil {
+		roundTrip = httpc.AuthRoundTrip(auth, roundTrip)
+	}
+
+	// retries without delays
+	for attempt := 0; ; attempt++ {
+		err = roundTrip(opts, resp)
+		if !strict || attempt >= retryCount || errors.Is(err, ErrPageNotFound) || opts.Context().Err() != nil {
+			break
+		}
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			break
+		}
+	}
+	if err != nil {
+		return resp, err
+	}
 
-	if len(response.Resp.BodyRaw) > 0 && opts.RepObj != nil {
-		err = json.Unmarshal(response.Resp.BodyRaw, opts.RepObj)
+	if strict && resp.StatusCode > 0 && !resp.StatusCodeSuccess {
+		return resp, dopErrs.BadStatusCode
+	}
+
+	if len(resp.BodyRaw) > 0 && opts.RepObj != nil {
+		err = json.Unmarshal(resp.BodyRaw, opts.RepObj)
 		if err != nil {
 			c.lg.Errorw("Fail to unmarshal json", err)
 			return nil, err
 		}
 	}
 
-	return response.Resp, nil
+	return resp, nil
+}
+
+// roundTrip - returns strict flag of found response
+func (c *St) roundTrip(opts *httpc.OptionsSt, resp *httpc.RespSt) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := opts.Context().Err(); err != nil {
+		return false, err
+	}
+
+	c.requests = append(c.requests, opts)
+
+	response, ok := c.findResponse(opts)
+	if !ok {
+		c.lg.Infow("Httpc-mock, path not found", "path", opts.Uri)
+		return false, ErrPageNotFound
+	}
+
+	*resp = *response.Resp
+	resp.Lg = c.lg
+	resp.ReqOpts = opts
+
+	if resp.StatusCode > 0 {
+		resp.StatusCodeSuccess = resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+	}
+
+	if !response.strict {
+		return false, nil
+	}
+
+	return true, response.Err
+}
+
+// findResponse - must be called under lock
+func (c *St) findResponse(opts *httpc.OptionsSt) (ResponseSt, bool) {
+	for _, e := range c.expectations {
+		if e.matcher.Match(opts) {
+			return e.next(), true
+		}
+	}
+
+	response, ok := c.responses[opts.Uri]
+
+	return response, ok
 }
 
 func (c *St) GetRequests() []*httpc.OptionsSt {
@@ -155,4 +347,5 @@ func (c *St) Clean() {
 
 	c.requests = []*httpc.OptionsSt{}
 	c.responses = map[string]ResponseSt{}
+	c.expectations = []*ExpectationSt{}
 }
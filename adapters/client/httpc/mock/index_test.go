@@ -0,0 +1,224 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/adapters/client/httpc/httpclient"
+	"github.com/rendau/dop/adapters/logger/zap"
+	"github.com/rendau/dop/dopErrs"
+	"github.com/stretchr/testify/require"
+)
+
+var lg = zap.New("info", true)
+
+func TestInterceptors(t *testing.T) {
+	m := New(lg)
+
+	m.SetResponse("path", ResponseSt{RespObj: map[string]string{"a": "1"}})
+
+	m.SetOptions(&httpc.OptionsSt{
+		Interceptors: []httpc.Interceptor{
+			func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+				opts.Headers.Set("Authorization", "Bearer token")
+				return next(opts, resp)
+			},
+		},
+	})
+
+	var bodyRaw []byte
+
+	repObj := map[string]string{}
+
+	_, err := m.Send(&httpc.OptionsSt{
+		Uri:    "path",
+		RepObj: &repObj,
+		Interceptors: []httpc.Interceptor{
+			func(opts *httpc.OptionsSt, resp *httpc.RespSt, next httpc.RoundTripFn) error {
+				err := next(opts, resp)
+				bodyRaw = resp.BodyRaw
+				return err
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"a": "1"}, repObj)
+	require.JSONEq(t, `{"a":"1"}`, string(bodyRaw))
+
+	req, ok := m.GetRequest("path", nil)
+	require.True(t, ok)
+	require.Equal(t, "Bearer token", req.Headers.Get("Authorization"))
+}
+
+func TestSendCtx(t *testing.T) {
+	m := New(lg)
+
+	m.SetResponse("path", ResponseSt{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := m.SendCtx(ctx, &httpc.OptionsSt{Uri: "path"})
+	require.Nil(t, err)
+
+	cancel()
+
+	_, err = m.SendCtx(ctx, &httpc.OptionsSt{Uri: "path"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// ctx from base options
+	m.SetOptions(&httpc.OptionsSt{Ctx: ctx})
+
+	_, err = m.Send(&httpc.OptionsSt{Uri: "path"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// nil ctx
+	_, err = m.SendCtx(nil, &httpc.OptionsSt{Uri: "path"})
+	require.Nil(t, err)
+}
+
+func TestExpect(t *testing.T) {
+	m := New(lg)
+
+	m.SetResponse("users/1", ResponseSt{RespObj: map[string]string{"src": "legacy"}})
+
+	getUser := m.Expect(MatcherSt{Method: "GET", Path: "users/*"}, ResponseSt{RespObj: map[string]string{"src": "expect"}}).Times(2)
+
+	createAdmin := m.Expect(
+		MatcherSt{Method: "POST", Path: "users", JsonBody: `{"role": "admin"}`},
+		ResponseSt{Err: errors.New("connection reset")},
+		ResponseSt{Resp: &httpc.RespSt{StatusCode: 503}},
+		ResponseSt{Resp: &httpc.RespSt{StatusCode: 201}, RespObj: map[string]int{"id": 7}},
+	)
+
+	createUser := m.Expect(
+		MatcherSt{Method: "POST", Path: "users"},
+		ResponseSt{Resp: &httpc.RespSt{StatusCode: 400}},
+	)
+
+	search := m.Expect(
+		MatcherSt{Path: "search", Query: url.Values{"q": {"x"}}, Headers: http.Header{"X-Tenant": {"t1"}}},
+		ResponseSt{},
+	).Times(1)
+
+	repObj := map[string]any{}
+
+	_, err := m.Send(&httpc.OptionsSt{Uri: "users/1", RepObj: &repObj})
+	require.Nil(t, err)
+	require.Equal(t, "expect", repObj["src"])
+
+	// fails twice, succeeds on retry
+	repObj = map[string]any{}
+	resp, err := m.Send(&httpc.OptionsSt{
+		Method:     "POST",
+		Uri:        "users",
+		ReqObj:     map[string]any{"name": "bob", "role": "admin"},
+		RepObj:     &repObj,
+		RetryCount: 2,
+	})
+	require.Nil(t, err)
+	require.Equal(t, 201, resp.StatusCode)
+	require.EqualValues(t, 7, repObj["id"])
+	require.Equal(t, 3, createAdmin.Calls())
+
+	_, err = m.Send(&httpc.OptionsSt{
+		Method: "POST",
+		Uri:    "users",
+		ReqObj: map[string]any{"name": "bob", "role": "user"},
+	})
+	require.ErrorIs(t, err, dopErrs.BadStatusCode)
+	require.Equal(t, 1, createUser.Calls())
+
+	_, err = m.Send(&httpc.OptionsSt{Uri: "search", Params: url.Values{"q": {"y"}}, Headers: http.Header{"X-Tenant": {"t1"}}})
+	require.ErrorIs(t, err, ErrPageNotFound)
+
+	_, err = m.Send(&httpc.OptionsSt{Uri: "search", Params: url.Values{"q": {"x"}, "p": {"1"}}, Headers: http.Header{"X-Tenant": {"t1"}}})
+	require.Nil(t, err)
+	require.Equal(t, 1, search.Calls())
+
+	require.Equal(t, 4, m.CallCount(MatcherSt{Method: "POST", Path: "users"}))
+	require.Equal(t, 3, m.CallCount(MatcherSt{JsonBody: map[string]string{"role": "admin"}}))
+
+	require.ErrorContains(t, m.Verify(), "GET users/*: expected 2 calls, got 1")
+
+	_, err = m.Send(&httpc.OptionsSt{Uri: "users/2"})
+	require.Nil(t, err)
+	require.Equal(t, 2, getUser.Calls())
+	require.Nil(t, m.Verify())
+}
+
+func TestSetResponse(t *testing.T) {
+	m := New(lg)
+
+	m.SetResponse("users", ResponseSt{Resp: &httpc.RespSt{StatusCode: 500}, RespObj: map[string]string{"error": "x"}})
+
+	repObj := map[string]any{}
+
+	// canned response is returned as is, without retries and status check
+	resp, err := m.Send(&httpc.OptionsSt{Uri: "users", RepObj: &repObj, RetryCount: 2})
+	require.Nil(t, err)
+	require.Equal(t, 500, resp.StatusCode)
+	require.Equal(t, "x", repObj["error"])
+	require.Equal(t, 1, m.CallCount(MatcherSt{Path: "users"}))
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/send":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"echo":` + string(body) + `}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	rec := NewRecorder(httpclient.New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      srv.URL,
+		LogFlags: httpc.NoLogError,
+	}))
+
+	send := func(hc httpc.HttpC) (map[string]any, error, error) {
+		repObj := map[string]any{}
+
+		_, err1 := hc.Send(&httpc.OptionsSt{
+			Method: "POST",
+			Uri:    "send",
+			ReqObj: map[string]string{"text": "hi"},
+			RepObj: &repObj,
+		})
+
+		_, err2 := hc.Send(&httpc.OptionsSt{Uri: "missing", Params: url.Values{"a": {"1"}}})
+
+		return repObj, err1, err2
+	}
+
+	recRep, recErr1, recErr2 := send(rec)
+	require.Nil(t, recErr1)
+	require.ErrorIs(t, recErr2, dopErrs.BadStatusCode)
+
+	fileName := filepath.Join(t.TempDir(), "fixtures.json")
+
+	require.Nil(t, rec.Save(fileName))
+
+	m := New(lg)
+	require.Nil(t, m.ReplayFile(fileName))
+
+	rep, err1, err2 := send(m)
+	require.Nil(t, err1)
+	require.ErrorIs(t, err2, dopErrs.BadStatusCode)
+	require.Equal(t, recRep, rep)
+	require.Equal(t, map[string]any{"echo": map[string]any{"text": "hi"}}, rep)
+
+	_, err := m.Send(&httpc.OptionsSt{Method: "POST", Uri: "send", ReqObj: map[string]string{"text": "bye"}})
+	require.ErrorIs(t, err, ErrPageNotFound)
+}
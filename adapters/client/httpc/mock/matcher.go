@@ -0,0 +1,199 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+)
+
+// MatcherSt - empty fields match any request
+type MatcherSt struct {
+	Method string
+	// Path - request Uri (without query) or pattern of path.Match, e.g. "users/*"
+	Path string
+	// Query - params which must be present with the same values
+	Query url.Values
+	// Headers - headers which must be present with the same values
+	Headers http.Header
+	// JsonBody - value (or json string/[]byte) which must be a subset of json request body
+	JsonBody any
+}
+
+func (m MatcherSt) String() string {
+	method := m.Method
+	if method == "" {
+		method = "*"
+	}
+
+	p := m.Path
+	if p == "" {
+		p = "*"
+	}
+
+	return method + " " + p
+}
+
+func (m MatcherSt) Match(opts *httpc.OptionsSt) bool {
+	if m.Method != "" {
+		method := opts.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		if !strings.EqualFold(m.Method, method) {
+			return false
+		}
+	}
+
+	if m.Path != "" {
+		uri, _, _ := strings.Cut(opts.Uri, "?")
+		if uri != m.Path {
+			if ok, _ := path.Match(m.Path, uri); !ok {
+				return false
+			}
+		}
+	}
+
+	for k, vs := range m.Query {
+		if !reflect.DeepEqual(opts.Params[k], vs) {
+			return false
+		}
+	}
+
+	for k, vs := range m.Headers {
+		if !reflect.DeepEqual(opts.Headers.Values(k), vs) {
+			return false
+		}
+	}
+
+	if m.JsonBody != nil {
+		want, err := jsonGeneric(m.JsonBody)
+		if err != nil {
+			return false
+		}
+
+		var got any
+
+		if json.Unmarshal(opts.ReqBody, &got) != nil {
+			return false
+		}
+
+		if !jsonSubset(want, got) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func jsonGeneric(v any) (any, error) {
+	var err error
+	var raw []byte
+
+	switch val := v.(type) {
+	case string:
+		raw = []byte(val)
+	case []byte:
+		raw = val
+	case json.RawMessage:
+		raw = val
+	default:
+		raw, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result any
+
+	err = json.Unmarshal(raw, &result)
+
+	return result, err
+}
+
+// jsonSubset - objects may have extra keys in got, arrays and scalars must be equal
+func jsonSubset(want, got any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok || !jsonSubset(wv, gv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !jsonSubset(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(want, got)
+}
+
+type ExpectationSt struct {
+	matcher   MatcherSt
+	responses []ResponseSt
+	calls     int
+	times     int
+	mu        sync.Mutex
+}
+
+// Times - sets expected count of calls, checked by St.Verify
+func (e *ExpectationSt) Times(n int) *ExpectationSt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.times = n
+
+	return e
+}
+
+func (e *ExpectationSt) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.calls
+}
+
+func (e *ExpectationSt) next() ResponseSt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.calls
+	if i >= len(e.responses) {
+		i = len(e.responses) - 1
+	}
+
+	e.calls++
+
+	return e.responses[i]
+}
+
+func (e *ExpectationSt) verify() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.times >= 0 && e.calls != e.times {
+		return fmt.Errorf("%s: expected %d calls, got %d", e.matcher, e.times, e.calls)
+	}
+
+	return nil
+}
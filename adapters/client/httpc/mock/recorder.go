@@ -0,0 +1,198 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopErrs"
+)
+
+type FixtureSt struct {
+	Method     string      `json:"method,omitempty"`
+	Uri        string      `json:"uri"`
+	Query      url.Values  `json:"query,omitempty"`
+	ReqBody    string      `json:"req_body,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	Headers    http.Header `json:"headers,omitempty"`
+	RepBody    string      `json:"rep_body,omitempty"`
+	Err        string      `json:"error,omitempty"`
+}
+
+// RecorderSt - wraps real client and records its traffic as fixtures for St.Replay.
+// Fields are taken from options of Send call, as the mock sees them. Stream responses are not recorded
+type RecorderSt struct {
+	client httpc.HttpC
+
+	fixtures []*FixtureSt
+	mu       sync.Mutex
+}
+
+func NewRecorder(client httpc.HttpC) *RecorderSt {
+	return &RecorderSt{
+		client:   client,
+		fixtures: []*FixtureSt{},
+	}
+}
+
+func (r *RecorderSt) GetOptions() *httpc.OptionsSt {
+	return r.client.GetOptions()
+}
+
+func (r *RecorderSt) SetOptions(opts *httpc.OptionsSt) {
+	r.client.SetOptions(opts)
+}
+
+func (r *RecorderSt) Send(opts *httpc.OptionsSt) (*httpc.RespSt, error) {
+	return r.record(opts, func() (*httpc.RespSt, error) {
+		return r.client.Send(opts)
+	})
+}
+
+func (r *RecorderSt) SendCtx(ctx context.Context, opts *httpc.OptionsSt) (*httpc.RespSt, error) {
+	return r.record(opts, func() (*httpc.RespSt, error) {
+		return r.client.SendCtx(ctx, opts)
+	})
+}
+
+func (r *RecorderSt) record(opts *httpc.OptionsSt, send func() (*httpc.RespSt, error)) (*httpc.RespSt, error) {
+	fixture := &FixtureSt{
+		Method: opts.Method,
+		Uri:    opts.Uri,
+		Query:  opts.Params,
+	}
+
+	switch {
+	case opts.ReqObj != nil:
+		if reqBody, err := json.Marshal(opts.ReqObj); err == nil {
+			fixture.ReqBody = string(reqBody)
+		}
+	case opts.ReqForm != nil:
+		fixture.ReqBody = httpc.FormValues(opts.ReqForm).Encode()
+	default:
+		fixture.ReqBody = string(opts.ReqBody)
+	}
+
+	resp, err := send()
+
+	if resp != nil {
+		fixture.StatusCode = resp.StatusCode
+		fixture.Headers = resp.Headers
+		fixture.RepBody = string(resp.BodyRaw)
+	}
+
+	if err != nil && !errors.Is(err, dopErrs.BadStatusCode) { // bad status code is restored from StatusCode
+		fixture.Err = err.Error()
+	}
+
+	r.mu.Lock()
+	r.fixtures = append(r.fixtures, fixture)
+	r.mu.Unlock()
+
+	return resp, err
+}
+
+func (r *RecorderSt) Fixtures() []*FixtureSt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]*FixtureSt, len(r.fixtures))
+	copy(result, r.fixtures)
+
+	return result
+}
+
+// Save - writes recorded fixtures to json file
+func (r *RecorderSt) Save(fileName string) error {
+	data, err := json.MarshalIndent(r.Fixtures(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, data, 0o644)
+}
+
+func LoadFixtures(fileName string) ([]*FixtureSt, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*FixtureSt, 0)
+
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Replay - registers fixtures as expectations, fixtures of the same request become a response sequence
+func (c *St) Replay(fixtures []*FixtureSt) {
+	type groupSt struct {
+		matcher   MatcherSt
+		responses []ResponseSt
+	}
+
+	groups := make([]*groupSt, 0)
+
+	for _, f := range fixtures {
+		m := MatcherSt{
+			Method: f.Method,
+			Path:   f.Uri,
+			Query:  f.Query,
+		}
+		if f.ReqBody != "" && json.Valid([]byte(f.ReqBody)) {
+			m.JsonBody = json.RawMessage(f.ReqBody)
+		}
+
+		response := ResponseSt{
+			Resp: &httpc.RespSt{
+				StatusCode: f.StatusCode,
+				Headers:    f.Headers,
+				BodyRaw:    []byte(f.RepBody),
+			},
+		}
+		if f.Err != "" {
+			response.Err = errors.New(f.Err)
+		}
+
+		var group *groupSt
+
+		for _, g := range groups {
+			if reflect.DeepEqual(g.matcher, m) {
+				group = g
+				break
+			}
+		}
+
+		if group == nil {
+			group = &groupSt{matcher: m}
+			groups = append(groups, group)
+		}
+
+		group.responses = append(group.responses, response)
+	}
+
+	for _, g := range groups {
+		c.Expect(g.matcher, g.responses...)
+	}
+}
+
+func (c *St) ReplayFile(fileName string) error {
+	fixtures, err := LoadFixtures(fileName)
+	if err != nil {
+		return err
+	}
+
+	c.Replay(fixtures)
+
+	return nil
+}
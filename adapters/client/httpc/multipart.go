@@ -0,0 +1,121 @@
+package httpc
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// FormValues - returns v if it is url.Values, encodes struct through Object2UrlValues otherwise
+func FormValues(v any) url.Values {
+	switch val := v.(type) {
+	case url.Values:
+		return val
+	case *url.Values:
+		return *val
+	case map[string][]string:
+		return val
+	}
+
+	return Object2UrlValues(v)
+}
+
+// Retryable - all files can be reopened
+func (m *MultipartSt) Retryable() bool {
+	for _, f := range m.Files {
+		if f.Open == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewBoundary - returns random boundary for Stream
+func (m *MultipartSt) NewBoundary() string {
+	return multipart.NewWriter(io.Discard).Boundary()
+}
+
+// Stream - returns body which is written on the fly, must be read to end or closed
+func (m *MultipartSt) Stream(boundary string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(m.write(pw, boundary))
+	}()
+
+	return pr
+}
+
+func (m *MultipartSt) write(w io.Writer, boundary string) error {
+	var err error
+
+	mw := multipart.NewWriter(w)
+
+	err = mw.SetBoundary(boundary)
+	if err != nil {
+		return err
+	}
+
+	for k, vs := range m.Fields {
+		for _, v := range vs {
+			err = mw.WriteField(k, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range m.Files {
+		err = m.writeFile(mw, f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+func (m *MultipartSt) writeFile(mw *multipart.Writer, f *MultipartFileSt) error {
+	var err error
+
+	r := f.Reader
+	if f.Open != nil {
+		r, err = f.Open()
+		if err != nil {
+			return fmt.Errorf("fail to open multipart file %q: %w", f.FileName, err)
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	if r == nil {
+		return fmt.Errorf("multipart file %q has no content", f.FileName)
+	}
+
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(
+		`form-data; name="%s"; filename="%s"`,
+		multipartQuoteEscaper.Replace(f.Field), multipartQuoteEscaper.Replace(f.FileName),
+	))
+	h.Set("Content-Type", contentType)
+
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(pw, r)
+
+	return err
+}
+
+var multipartQuoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
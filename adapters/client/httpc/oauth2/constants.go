@@ -0,0 +1,25 @@
+package oauth2
+
+import (
+	"time"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// ErrorCodeInvalidGrant - refresh token is expired or revoked
+const ErrorCodeInvalidGrant = "invalid_grant"
+
+const (
+	// AuthStyleBody - client credentials are sent as form params
+	AuthStyleBody = 0
+	// AuthStyleHeader - client credentials are sent as basic auth
+	AuthStyleHeader = 1
+)
+
+var defaultOptions = OptionsSt{
+	ExpiryDelta:  30 * time.Second,
+	FetchTimeout: 30 * time.Second,
+}
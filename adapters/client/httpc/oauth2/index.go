@@ -0,0 +1,203 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/dopTools"
+	"golang.org/x/sync/singleflight"
+)
+
+// St - httpc.AuthProvider with client-credentials and refresh-token grants,
+// token is cached and refreshed before expiry, concurrent refreshes are deduplicated
+type St struct {
+	client httpc.HttpC
+	opts   OptionsSt
+
+	token        string
+	expiresAt    time.Time
+	refreshToken string
+	mu           sync.Mutex
+
+	group singleflight.Group
+}
+
+// New - client is used for token requests, its Uri is a base of TokenUri
+func New(client httpc.HttpC, opts OptionsSt) *St {
+	opts.mergeWithDefaults()
+
+	return &St{
+		client:       client,
+		opts:         opts,
+		refreshToken: opts.RefreshToken,
+	}
+}
+
+func (s *St) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	token := s.validToken(time.Now())
+	s.mu.Unlock()
+
+	if token != "" {
+		return token, nil
+	}
+
+	return s.fetch(ctx)
+}
+
+func (s *St) Refresh(ctx context.Context, rejected string) (string, error) {
+	s.mu.Lock()
+	if s.token == rejected {
+		s.token = ""
+	}
+	token := s.validToken(time.Now()) // already refreshed by concurrent request
+	s.mu.Unlock()
+
+	if token != "" {
+		return token, nil
+	}
+
+	return s.fetch(ctx)
+}
+
+func (s *St) validToken(now time.Time) string {
+	if s.token == "" || (!s.expiresAt.IsZero() && !now.Before(s.expiresAt)) {
+		return ""
+	}
+
+	return s.token
+}
+
+// fetch - concurrent callers share one token request, each waits with own ctx
+func (s *St) fetch(ctx context.Context) (string, error) {
+	ch := s.group.DoChan("token", func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(dopTools.DetachedContext(ctx), s.opts.FetchTimeout)
+		defer cancel()
+
+		return s.requestToken(fetchCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return "", r.Err
+		}
+		return r.Val.(string), nil
+	}
+}
+
+func (s *St) requestToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+
+	token, err := s.requestGrant(ctx, refreshToken)
+	if err == nil || refreshToken == "" {
+		return token, err
+	}
+
+	oErr := &ErrorSt{}
+	if !errors.As(err, &oErr) || oErr.Code != ErrorCodeInvalidGrant {
+		return token, err
+	}
+
+	// refresh token is expired or revoked, it is useless for next requests
+	s.mu.Lock()
+	if s.refreshToken == refreshToken {
+		s.refreshToken = ""
+	}
+	s.mu.Unlock()
+
+	if s.opts.ClientId == "" || s.opts.ClientSecret == "" {
+		return token, err
+	}
+
+	return s.requestGrant(ctx, "")
+}
+
+// requestGrant - requests token with refresh_token grant, or client_credentials if refreshToken is empty
+func (s *St) requestGrant(ctx context.Context, refreshToken string) (string, error) {
+	form := url.Values{}
+	for k, v := range s.opts.Params {
+		form[k] = v
+	}
+
+	if refreshToken != "" {
+		form.Set("grant_type", GrantTypeRefreshToken)
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", GrantTypeClientCredentials)
+	}
+
+	if len(s.opts.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.opts.Scopes, " "))
+	}
+
+	reqOpts := &httpc.OptionsSt{
+		Method:  http.MethodPost,
+		Uri:     s.opts.TokenUri,
+		ReqForm: form,
+	}
+
+	if s.opts.AuthStyle == AuthStyleHeader {
+		reqOpts.BasicAuthCreds = &httpc.BasicAuthCredsSt{
+			Username: url.QueryEscape(s.opts.ClientId),
+			Password: url.QueryEscape(s.opts.ClientSecret),
+		}
+	} else {
+		if s.opts.ClientId != "" {
+			form.Set("client_id", s.opts.ClientId)
+		}
+		if s.opts.ClientSecret != "" {
+			form.Set("client_secret", s.opts.ClientSecret)
+		}
+	}
+
+	now := time.Now()
+
+	rep, _, err := httpc.DoWithErr[TokenSt, ErrorSt](ctx, s.client, reqOpts, nil)
+	if err != nil {
+		var statusErr *httpc.StatusErr[ErrorSt]
+		if errors.As(err, &statusErr) {
+			res := statusErr.Body
+			res.StatusCode = statusErr.StatusCode
+			return "", &res
+		}
+		return "", err
+	}
+
+	if rep.AccessToken == "" {
+		return "", &ErrorSt{Code: "invalid_response", Description: "empty access_token"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = rep.AccessToken
+	s.expiresAt = time.Time{}
+
+	if rep.ExpiresIn > 0 {
+		lifetime := time.Duration(rep.ExpiresIn) * time.Second
+
+		delta := s.opts.ExpiryDelta
+		if delta > lifetime/2 {
+			delta = lifetime / 2
+		}
+
+		s.expiresAt = now.Add(lifetime - delta)
+	}
+
+	if rep.RefreshToken != "" {
+		s.refreshToken = rep.RefreshToken
+	}
+
+	return s.token, nil
+}
@@ -0,0 +1,292 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/adapters/client/httpc/httpclient"
+	"github.com/rendau/dop/adapters/logger/zap"
+	"github.com/stretchr/testify/require"
+)
+
+var lg = zap.New("info", true)
+
+type tokenSrvSt struct {
+	*httptest.Server
+
+	calls     int32
+	expiresIn int64
+	forms     chan map[string]string
+}
+
+func newTokenSrv(t *testing.T) *tokenSrvSt {
+	s := &tokenSrvSt{forms: make(chan map[string]string, 100)}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&s.calls, 1)
+
+		if r.ParseForm() != nil || r.URL.Path != "/token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		if user, pass, ok := r.BasicAuth(); ok {
+			form["basic"] = user + ":" + pass
+		}
+		s.forms <- form
+
+		if form["client_id"] == "bad" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+			return
+		}
+
+		if form["refresh_token"] == "expired" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(TokenSt{
+			AccessToken:  "t" + strconv.Itoa(int(n)),
+			TokenType:    "Bearer",
+			ExpiresIn:    atomic.LoadInt64(&s.expiresIn),
+			RefreshToken: "r" + strconv.Itoa(int(n)),
+		})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *tokenSrvSt) provider(opts OptionsSt) *St {
+	opts.TokenUri = "token"
+
+	return New(httpclient.New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      s.URL,
+		LogFlags: httpc.NoLogError,
+	}), opts)
+}
+
+func TestClientCredentials(t *testing.T) {
+	tokenSrv := newTokenSrv(t)
+
+	p := tokenSrv.provider(OptionsSt{ClientId: "id", ClientSecret: "secret", Scopes: []string{"a", "b"}})
+
+	// concurrent requests share one token request
+	type resultSt struct {
+		token string
+		err   error
+	}
+
+	results := make(chan resultSt, 10)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := p.Token(context.Background())
+			results <- resultSt{token, err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		require.Nil(t, r.err)
+		require.Equal(t, "t1", r.token)
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&tokenSrv.calls))
+	require.Equal(t, map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     "id",
+		"client_secret": "secret",
+		"scope":         "a b",
+	}, <-tokenSrv.forms)
+
+	// refresh of stale token returns current one
+	token, err := p.Refresh(context.Background(), "t0")
+	require.Nil(t, err)
+	require.Equal(t, "t1", token)
+
+	token, err = p.Refresh(context.Background(), "t1")
+	require.Nil(t, err)
+	require.Equal(t, "t2", token)
+
+	// basic auth
+	p = tokenSrv.provider(OptionsSt{ClientId: "id:1", ClientSecret: "s", AuthStyle: AuthStyleHeader})
+	_, err = p.Token(context.Background())
+	require.Nil(t, err)
+	<-tokenSrv.forms
+	require.Equal(t, map[string]string{"grant_type": "client_credentials", "basic": "id%3A1:s"}, <-tokenSrv.forms)
+
+	// error response
+	p = tokenSrv.provider(OptionsSt{ClientId: "bad"})
+	_, err = p.Token(context.Background())
+	oErr := &ErrorSt{}
+	require.True(t, errors.As(err, &oErr))
+	require.Equal(t, http.StatusUnauthorized, oErr.StatusCode)
+	require.Equal(t, "invalid_client", oErr.Code)
+	require.Equal(t, "unknown client", oErr.Description)
+}
+
+func TestExpiryAndRefreshToken(t *testing.T) {
+	tokenSrv := newTokenSrv(t)
+	tokenSrv.expiresIn = 1
+
+	p := tokenSrv.provider(OptionsSt{ClientId: "id", RefreshToken: "r0"})
+
+	token, err := p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "t1", token)
+	require.Equal(t, "r0", (<-tokenSrv.forms)["refresh_token"])
+
+	token, err = p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "t1", token)
+
+	// refreshed at half of short lifetime, with rotated refresh token
+	time.Sleep(600 * time.Millisecond)
+
+	token, err = p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "t2", token)
+	form := <-tokenSrv.forms
+	require.Equal(t, "refresh_token", form["grant_type"])
+	require.Equal(t, "r1", form["refresh_token"])
+
+	// client_id is not sent if it is not set
+	p = tokenSrv.provider(OptionsSt{RefreshToken: "r0"})
+
+	_, err = p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"grant_type": "refresh_token", "refresh_token": "r0"}, <-tokenSrv.forms)
+}
+
+func TestInvalidGrant(t *testing.T) {
+	tokenSrv := newTokenSrv(t)
+
+	// falls back to client_credentials
+	p := tokenSrv.provider(OptionsSt{ClientId: "id", ClientSecret: "secret", RefreshToken: "expired"})
+
+	token, err := p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "t2", token)
+	require.Equal(t, GrantTypeRefreshToken, (<-tokenSrv.forms)["grant_type"])
+	require.Equal(t, GrantTypeClientCredentials, (<-tokenSrv.forms)["grant_type"])
+
+	// without client secret error is returned, but refresh token is dropped
+	p = tokenSrv.provider(OptionsSt{ClientId: "id", RefreshToken: "expired"})
+
+	_, err = p.Token(context.Background())
+	oErr := &ErrorSt{}
+	require.True(t, errors.As(err, &oErr))
+	require.Equal(t, ErrorCodeInvalidGrant, oErr.Code)
+	require.Equal(t, GrantTypeRefreshToken, (<-tokenSrv.forms)["grant_type"])
+
+	token, err = p.Token(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "t4", token)
+	require.Equal(t, GrantTypeClientCredentials, (<-tokenSrv.forms)["grant_type"])
+}
+
+func TestFetchCanceledWaiter(t *testing.T) {
+	tokenSrv := newTokenSrv(t)
+
+	p := tokenSrv.provider(OptionsSt{ClientId: "id"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := p.Token(ctx)
+		firstErr <- err
+	}()
+
+	// wait for token request of first caller
+	<-tokenSrv.forms
+
+	secondToken := make(chan string, 1)
+	secondErr := make(chan error, 1)
+	go func() {
+		token, err := p.Token(context.Background())
+		secondToken <- token
+		secondErr <- err
+	}()
+
+	// first caller gives up, token request continues for second one
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+
+	require.Nil(t, <-secondErr)
+	require.Equal(t, "t1", <-secondToken)
+	require.EqualValues(t, 1, atomic.LoadInt32(&tokenSrv.calls))
+}
+
+func TestAuthRetry(t *testing.T) {
+	tokenSrv := newTokenSrv(t)
+
+	var apiCalls int32
+
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&apiCalls, 1)
+		if r.Header.Get("Authorization") != "Bearer t2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer apiSrv.Close()
+
+	hc := httpclient.New(lg, &httpc.OptionsSt{
+		Client:   http.DefaultClient,
+		Uri:      apiSrv.URL,
+		LogFlags: httpc.NoLogError,
+		Auth:     tokenSrv.provider(OptionsSt{ClientId: "id"}),
+	})
+
+	rep := struct {
+		Ok bool `json:"ok"`
+	}{}
+
+	_, err := hc.Send(&httpc.OptionsSt{Uri: "data", RepObj: &rep})
+	require.Nil(t, err)
+	require.True(t, rep.Ok)
+	require.EqualValues(t, 2, atomic.LoadInt32(&apiCalls))
+	require.EqualValues(t, 2, atomic.LoadInt32(&tokenSrv.calls))
+
+	// cached token
+	_, err = hc.Send(&httpc.OptionsSt{Uri: "data"})
+	require.Nil(t, err)
+	require.EqualValues(t, 3, atomic.LoadInt32(&apiCalls))
+	require.EqualValues(t, 2, atomic.LoadInt32(&tokenSrv.calls))
+
+	// retried only once
+	hc.GetOptions().Auth = tokenSrv.provider(OptionsSt{ClientId: "id"})
+	atomic.StoreInt32(&apiCalls, 0)
+
+	resp, err := hc.Send(&httpc.OptionsSt{Uri: "data"})
+	require.NotNil(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.EqualValues(t, 2, atomic.LoadInt32(&apiCalls))
+}
@@ -0,0 +1,66 @@
+package oauth2
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rendau/dop/dopErrs"
+)
+
+type OptionsSt struct {
+	// TokenUri - appended to Uri of client
+	TokenUri     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken - if set, refresh_token grant is used instead of client_credentials, rotated token is kept,
+	// on "invalid_grant" it is dropped and client_credentials is used if ClientId and ClientSecret are set
+	RefreshToken string
+	// Params - extra form params of token request, e.g. "audience"
+	Params    url.Values
+	AuthStyle int
+	// ExpiryDelta - token is refreshed this time before expiry, default 30s
+	ExpiryDelta time.Duration
+	// FetchTimeout - timeout of token request, it is shared by waiters and not canceled with context of caller, default 30s
+	FetchTimeout time.Duration
+}
+
+func (o *OptionsSt) mergeWithDefaults() {
+	if o.ExpiryDelta <= 0 {
+		o.ExpiryDelta = defaultOptions.ExpiryDelta
+	}
+	if o.FetchTimeout <= 0 {
+		o.FetchTimeout = defaultOptions.FetchTimeout
+	}
+}
+
+type TokenSt struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// ErrorSt - error response of token endpoint (RFC 6749, section 5.2)
+type ErrorSt struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *ErrorSt) Error() string {
+	switch {
+	case e.Code == "":
+		return "oauth2: " + dopErrs.BadStatusCode.Error() + ": " + strconv.Itoa(e.StatusCode)
+	case e.Description != "":
+		return "oauth2: " + e.Code + ": " + e.Description
+	}
+
+	return "oauth2: " + e.Code
+}
+
+func (e *ErrorSt) Unwrap() error {
+	return dopErrs.BadStatusCode
+}
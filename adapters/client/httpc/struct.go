@@ -1,38 +1,185 @@
 package httpc
 
 import (
+	"context"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/rendau/dop/adapters/logger"
+	"github.com/rendau/dop/adapters/trace"
+	"github.com/rendau/dop/dopErrs"
 )
 
 // Options
 
 type OptionsSt struct {
+	// Ctx - cancellation and deadline of request (including retries), Timeout derives from it
+	Ctx            context.Context
 	Client         *http.Client
 	Uri            string
 	Method         string
 	Params         url.Values
 	Headers        http.Header
 	BasicAuthCreds *BasicAuthCredsSt
-	LogFlags       int
-	LogPrefix      string
-	RetryCount     int
-	RetryInterval  time.Duration
-	Timeout        time.Duration
-
-	ReqClose     bool
-	ReqStream    io.Reader
-	ReqBody      []byte
-	ReqObj       any
-	RepStream    bool
-	RepObj       any
+	// Auth - sets "Authorization: Bearer" header, request is retried once with refreshed token on 401
+	Auth      AuthProvider
+	LogFlags  int
+	LogPrefix string
+	// LogBodyMaxSize - bodies are truncated to it in logs, default 4096, -1 - unlimited
+	LogBodyMaxSize int
+	// LogRedactHeaders - headers hidden in logs, DefaultLogRedactHeaders if nil
+	LogRedactHeaders []string
+	// LogRedactFields - json fields and params (case-insensitive) hidden in logs, DefaultLogRedactFields if nil
+	LogRedactFields []string
+	RetryCount      int
+	RetryInterval   time.Duration
+	// RetryPolicy - backoff and retry conditions, nil - fixed RetryInterval, retry of idempotent requests on network errors and 5xx
+	RetryPolicy *RetryPolicySt
+	Timeout     time.Duration
+	// Breaker - circuit breaker per upstream, nil - disabled
+	Breaker *BreakerOptionsSt
+	// Limit - client-side rate limit and concurrency cap, nil - disabled
+	Limit *LimitOptionsSt
+	// Interceptors - wrap every round trip (each retry attempt), first is outermost
+	Interceptors []Interceptor
+
+	ReqClose  bool
+	ReqStream io.Reader
+	// ReqStreamFactory - creates request body for every attempt, makes stream requests retryable
+	ReqStreamFactory func() (io.Reader, error)
+	ReqBody          []byte
+	ReqObj           any
+	// ReqForm - url.Values or struct with "form" tags, sent url-encoded
+	ReqForm any
+	// ReqMultipart - sent as multipart/form-data without buffering
+	ReqMultipart *MultipartSt
+	// ReqCompression - CompressionGzip or CompressionDeflate, request body is compressed with "Content-Encoding" header
+	ReqCompression string
+	// MaxResponseBytes - limit of response body (also RepStream), ErrResponseTooLarge if exceeded, 0 - unlimited
+	MaxResponseBytes int64
+	RepStream        bool
+	RepObj           any
+	// RepObjStream - decode RepObj of successful response directly from body, BodyRaw is not kept
+	RepObjStream bool
 	StatusRepObj map[int]any
 }
 
+// RoundTripFn - sends request described by opts and fills resp
+type RoundTripFn func(opts *OptionsSt, resp *RespSt) error
+
+// Interceptor - wraps round trip, must call next to continue the chain
+type Interceptor func(opts *OptionsSt, resp *RespSt, next RoundTripFn) error
+
+type RetryPolicySt struct {
+	// Multiplier - delay of n-th retry is RetryInterval * Multiplier^n, default 2
+	Multiplier float64
+	// MaxDelay - upper bound of delay, 0 - unlimited
+	MaxDelay time.Duration
+	// Jitter - fraction (0..1) of delay which is randomized
+	Jitter float64
+	// NoRetryAfter - ignore "Retry-After" header of 429/503 responses
+	NoRetryAfter bool
+	// RetryNonIdempotent - retry also POST/PATCH requests without "Idempotency-Key" header
+	RetryNonIdempotent bool
+	// Predicate - decides to retry or not, DefaultRetryPredicate if nil
+	Predicate func(resp *RespSt, err error) bool
+}
+
+// Delay - returns delay before retry number attempt (starts from 0)
+func (p *RetryPolicySt) Delay(interval time.Duration, attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	delay := float64(interval) * math.Pow(multiplier, float64(attempt))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay -= delay * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+type BreakerOptionsSt struct {
+	// FailureThreshold - consecutive failures to open circuit, default 5
+	FailureThreshold int
+	// CoolDown - time in open state before probe requests, default 30s
+	CoolDown time.Duration
+	// HalfOpenMaxCalls - concurrent probe requests in half-open state, default 1
+	HalfOpenMaxCalls int
+	// Key - upstream key of request, host of Uri by default
+	Key func(opts *OptionsSt) string
+	// IsFailure - DefaultBreakerIsFailure by default, not called if request context is done
+	IsFailure func(resp *RespSt, err error) bool
+}
+
+func (o *BreakerOptionsSt) GetMergedWithDefaults() *BreakerOptionsSt {
+	res := *o
+
+	if res.FailureThreshold <= 0 {
+		res.FailureThreshold = defaultBreakerOptions.FailureThreshold
+	}
+	if res.CoolDown <= 0 {
+		res.CoolDown = defaultBreakerOptions.CoolDown
+	}
+	if res.HalfOpenMaxCalls <= 0 {
+		res.HalfOpenMaxCalls = defaultBreakerOptions.HalfOpenMaxCalls
+	}
+
+	return &res
+}
+
+type LimitOptionsSt struct {
+	// Rate - requests per second (token bucket), 0 - unlimited
+	Rate float64
+	// Burst - requests allowed at once above Rate, default 1
+	Burst int
+	// MaxInFlight - concurrent requests (stream response holds slot until it is closed), 0 - unlimited
+	MaxInFlight int
+	// Key - requests with the same key share limiter (it is reset if limits are changed), one limiter per client by default
+	Key func(opts *OptionsSt) string
+}
+
+func (o *LimitOptionsSt) GetMergedWithDefaults() *LimitOptionsSt {
+	res := *o
+
+	if res.Burst <= 0 {
+		res.Burst = defaultLimitOptions.Burst
+	}
+
+	return &res
+}
+
+type BreakerStateSt struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+type MultipartSt struct {
+	Fields url.Values
+	Files  []*MultipartFileSt
+}
+
+type MultipartFileSt struct {
+	Field       string
+	FileName    string
+	ContentType string
+	// Reader - content, read once, request with it is not retried
+	Reader io.Reader
+	// Open - opens content for every attempt, preferred over Reader, closed if io.Closer
+	Open func() (io.Reader, error)
+}
+
 type BasicAuthCredsSt struct {
 	Username string
 	Password string
@@ -40,17 +187,40 @@ type BasicAuthCredsSt struct {
 
 func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 	res := &OptionsSt{
-		Client:         o.Client,
-		Uri:            o.Uri + val.Uri,
-		Method:         o.Method,
-		Params:         url.Values{},
-		Headers:        http.Header{},
-		BasicAuthCreds: o.BasicAuthCreds,
-		LogFlags:       o.LogFlags,
-		LogPrefix:      o.LogPrefix + val.LogPrefix,
-		RetryCount:     o.RetryCount,
-		RetryInterval:  o.RetryInterval,
-		Timeout:        o.Timeout,
+		Ctx:              o.Ctx,
+		Client:           o.Client,
+		Uri:              o.Uri + val.Uri,
+		Method:           o.Method,
+		Params:           url.Values{},
+		Headers:          http.Header{},
+		BasicAuthCreds:   o.BasicAuthCreds,
+		Auth:             o.Auth,
+		LogFlags:         o.LogFlags,
+		LogPrefix:        o.LogPrefix + val.LogPrefix,
+		LogBodyMaxSize:   o.LogBodyMaxSize,
+		LogRedactHeaders: o.LogRedactHeaders,
+		LogRedactFields:  o.LogRedactFields,
+		RetryCount:       o.RetryCount,
+		RetryInterval:    o.RetryInterval,
+		RetryPolicy:      o.RetryPolicy,
+		Timeout:          o.Timeout,
+		Breaker:          o.Breaker,
+		Limit:            o.Limit,
+		ReqCompression:   o.ReqCompression,
+		MaxResponseBytes: o.MaxResponseBytes,
+		RepObjStream:     o.RepObjStream,
+	}
+
+	// Interceptors (base ones wrap call ones)
+	if len(o.Interceptors) > 0 || len(val.Interceptors) > 0 {
+		res.Interceptors = make([]Interceptor, 0, len(o.Interceptors)+len(val.Interceptors))
+		res.Interceptors = append(res.Interceptors, o.Interceptors...)
+		res.Interceptors = append(res.Interceptors, val.Interceptors...)
+	}
+
+	// Ctx
+	if val.Ctx != nil {
+		res.Ctx = val.Ctx
 	}
 
 	// Client
@@ -87,6 +257,11 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		res.BasicAuthCreds = val.BasicAuthCreds
 	}
 
+	// Auth
+	if val.Auth != nil {
+		res.Auth = val.Auth
+	}
+
 	// LogFlags
 	if val.LogFlags != 0 {
 		if val.LogFlags < 0 {
@@ -96,6 +271,21 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		}
 	}
 
+	// LogBodyMaxSize
+	if val.LogBodyMaxSize != 0 {
+		res.LogBodyMaxSize = val.LogBodyMaxSize
+	}
+
+	// LogRedactHeaders
+	if val.LogRedactHeaders != nil {
+		res.LogRedactHeaders = val.LogRedactHeaders
+	}
+
+	// LogRedactFields
+	if val.LogRedactFields != nil {
+		res.LogRedactFields = val.LogRedactFields
+	}
+
 	// RetryCount
 	if val.RetryCount != 0 {
 		if val.RetryCount < 0 {
@@ -114,6 +304,11 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		}
 	}
 
+	// RetryPolicy
+	if val.RetryPolicy != nil {
+		res.RetryPolicy = val.RetryPolicy
+	}
+
 	// Timeout
 	if val.Timeout != 0 {
 		if val.Timeout < 0 {
@@ -123,11 +318,26 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		}
 	}
 
+	// Breaker
+	if val.Breaker != nil {
+		res.Breaker = val.Breaker
+	}
+
+	// Limit
+	if val.Limit != nil {
+		res.Limit = val.Limit
+	}
+
 	// ReqStream
 	if val.ReqStream != nil {
 		res.ReqStream = val.ReqStream
 	}
 
+	// ReqStreamFactory
+	if val.ReqStreamFactory != nil {
+		res.ReqStreamFactory = val.ReqStreamFactory
+	}
+
 	// ReqBody
 	if val.ReqBody != nil {
 		res.ReqBody = val.ReqBody
@@ -138,6 +348,30 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		res.ReqObj = val.ReqObj
 	}
 
+	// ReqForm
+	if val.ReqForm != nil {
+		res.ReqForm = val.ReqForm
+	}
+
+	// ReqMultipart
+	if val.ReqMultipart != nil {
+		res.ReqMultipart = val.ReqMultipart
+	}
+
+	// ReqCompression
+	if val.ReqCompression != "" {
+		res.ReqCompression = val.ReqCompression
+	}
+
+	// MaxResponseBytes
+	if val.MaxResponseBytes != 0 {
+		if val.MaxResponseBytes < 0 {
+			res.MaxResponseBytes = 0
+		} else {
+			res.MaxResponseBytes = val.MaxResponseBytes
+		}
+	}
+
 	// RepStream
 	if val.RepStream {
 		res.RepStream = val.RepStream
@@ -148,6 +382,11 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 		res.RepObj = val.RepObj
 	}
 
+	// RepObjStream
+	if val.RepObjStream {
+		res.RepObjStream = val.RepObjStream
+	}
+
 	// StatusRepObj
 	if val.StatusRepObj != nil {
 		res.StatusRepObj = val.StatusRepObj
@@ -156,6 +395,27 @@ func (o *OptionsSt) GetMergedWith(val *OptionsSt) *OptionsSt {
 	return res
 }
 
+// Context - returns Ctx or background context if it is not set
+func (o *OptionsSt) Context() context.Context {
+	if o.Ctx != nil {
+		return o.Ctx
+	}
+
+	return context.Background()
+}
+
+// ReqBodyReusable - request body can be sent again (not consumed stream or file)
+func (o *OptionsSt) ReqBodyReusable() bool {
+	if o.ReqStream != nil && o.ReqStreamFactory == nil {
+		return false
+	}
+	if o.ReqMultipart != nil && !o.ReqMultipart.Retryable() {
+		return false
+	}
+
+	return true
+}
+
 func (o *OptionsSt) HasLogFlag(v int) bool {
 	return o.LogFlags&v > 0
 }
@@ -193,13 +453,64 @@ func (o *RespSt) LogInfo(title string, args ...any) {
 }
 
 func (o *RespSt) fillLogArgs(srcArgs ...any) []any {
-	return append(
+	opts := o.ReqOpts
+
+	redactHeaders := opts.LogRedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = DefaultLogRedactHeaders
+	}
+
+	redactFields := opts.LogRedactFields
+	if redactFields == nil {
+		redactFields = DefaultLogRedactFields
+	}
+
+	bodyMaxSize := opts.LogBodyMaxSize
+	if bodyMaxSize == 0 {
+		bodyMaxSize = defaultLogBodyMaxSize
+	}
+
+	args := append(
 		srcArgs,
-		"method", o.ReqOpts.Method,
-		"uri", o.ReqOpts.Uri,
-		"params", o.ReqOpts.Params.Encode(),
-		"req_body", string(o.ReqOpts.ReqBody),
+		"method", opts.Method,
+		"uri", opts.Uri,
+		"params", redactValues(opts.Params, redactFields).Encode(),
+	)
+
+	if len(opts.Headers) > 0 {
+		args = append(args, "req_headers", redactHeaderValues(opts.Headers, redactHeaders))
+	}
+
+	args = append(
+		args,
+		"req_body", truncateLogBody(redactBody(opts.ReqBody, opts.Headers.Get("Content-Type"), redactFields), bodyMaxSize),
 		"status_code", o.StatusCode,
-		"rep_body", string(o.BodyRaw),
 	)
+
+	if len(o.Headers) > 0 {
+		args = append(args, "rep_headers", redactHeaderValues(o.Headers, redactHeaders))
+	}
+
+	args = append(
+		args,
+		"rep_body", truncateLogBody(redactBody(o.BodyRaw, o.Headers.Get("Content-Type"), redactFields), bodyMaxSize),
+	)
+
+	return append(args, trace.LogArgs(opts.Context())...)
+}
+
+// StatusErr - bad status code error with decoded body, wraps dopErrs.BadStatusCode
+type StatusErr[T any] struct {
+	StatusCode  int
+	Body        T
+	BodyDecoded bool
+	BodyRaw     []byte
+}
+
+func (e *StatusErr[T]) Error() string {
+	return dopErrs.BadStatusCode.Error() + ": " + strconv.Itoa(e.StatusCode)
+}
+
+func (e *StatusErr[T]) Unwrap() error {
+	return dopErrs.BadStatusCode
 }
@@ -1,10 +1,15 @@
 package httpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func Object2UrlValues(obj any) url.Values {
@@ -55,3 +60,96 @@ func Object2UrlValues(obj any) url.Values {
 
 	return result
 }
+
+// ChainInterceptors - returns round trip wrapped by interceptors, first interceptor is outermost
+func ChainInterceptors(interceptors []Interceptor, rt RoundTripFn) RoundTripFn {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], rt
+		rt = func(opts *OptionsSt, resp *RespSt) error {
+			return interceptor(opts, resp, next)
+		}
+	}
+
+	return rt
+}
+
+// AuthRoundTrip - wraps round trip, sets bearer token of auth and retries once with refreshed token on 401
+func AuthRoundTrip(auth AuthProvider, rt RoundTripFn) RoundTripFn {
+	refreshed := false
+
+	return func(opts *OptionsSt, resp *RespSt) error {
+		ctx := opts.Context()
+
+		token, err := auth.Token(ctx)
+		if err != nil {
+			resp.Reset()
+			return fmt.Errorf("fail to get auth token: %w", err)
+		}
+
+		opts.Headers.Set("Authorization", "Bearer "+token)
+
+		err = rt(opts, resp)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || refreshed || !opts.ReqBodyReusable() {
+			return err
+		}
+
+		refreshed = true
+
+		token, err = auth.Refresh(ctx, token)
+		if err != nil {
+			return fmt.Errorf("fail to refresh auth token: %w", err)
+		}
+
+		opts.Headers.Set("Authorization", "Bearer "+token)
+
+		return rt(opts, resp)
+	}
+}
+
+// DefaultRetryPredicate - retries network errors (except context ones), 429 and 5xx
+func DefaultRetryPredicate(resp *RespSt, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// IsIdempotent - by method or "Idempotency-Key" header
+func IsIdempotent(opts *OptionsSt) bool {
+	switch strings.ToUpper(opts.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return opts.Headers.Get("Idempotency-Key") != ""
+}
+
+// ParseRetryAfter - parses "Retry-After" header (seconds or http-date)
+func ParseRetryAfter(headers http.Header, now time.Time) (time.Duration, bool) {
+	v := strings.TrimSpace(headers.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// DefaultBreakerIsFailure - network errors (including Timeout) and 5xx
+func DefaultBreakerIsFailure(resp *RespSt, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
@@ -1,12 +1,15 @@
 package httpc
 
 import (
+	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/rendau/dop/dopTools"
+	"github.com/stretchr/testify/require"
 )
 
 func TestObject2UrlValues(t *testing.T) {
@@ -62,3 +65,45 @@ func TestObject2UrlValues(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicySt{MaxDelay: time.Second}
+
+	require.Equal(t, 100*time.Millisecond, p.Delay(100*time.Millisecond, 0))
+	require.Equal(t, 200*time.Millisecond, p.Delay(100*time.Millisecond, 1))
+	require.Equal(t, 800*time.Millisecond, p.Delay(100*time.Millisecond, 3))
+	require.Equal(t, time.Second, p.Delay(100*time.Millisecond, 10))
+
+	p = &RetryPolicySt{Multiplier: 3, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		d := p.Delay(100*time.Millisecond, 1)
+		require.GreaterOrEqual(t, d, 150*time.Millisecond)
+		require.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d, ok := ParseRetryAfter(http.Header{"Retry-After": {"120"}}, now)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Minute, d)
+
+	d, ok = ParseRetryAfter(http.Header{"Retry-After": {now.Add(30 * time.Second).Format(http.TimeFormat)}}, now)
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, d)
+
+	_, ok = ParseRetryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	require.False(t, ok)
+
+	_, ok = ParseRetryAfter(http.Header{}, now)
+	require.False(t, ok)
+}
+
+func TestIsIdempotent(t *testing.T) {
+	require.True(t, IsIdempotent(&OptionsSt{Method: "GET"}))
+	require.True(t, IsIdempotent(&OptionsSt{Method: "delete"}))
+	require.False(t, IsIdempotent(&OptionsSt{Method: "POST"}))
+	require.True(t, IsIdempotent(&OptionsSt{Method: "POST", Headers: http.Header{"Idempotency-Key": {"k"}}}))
+}
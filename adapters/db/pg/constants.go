@@ -8,11 +8,18 @@ import (
 type transactionCtxKeyT int8
 
 const (
+	DriverPgxV4 = "pgx4"
+	DriverPgxV5 = "pgx5"
+	DriverSql   = "sql"
+
 	ErrPrefix         = "pg-error"
 	transactionCtxKey = transactionCtxKeyT(1)
+
+	defaultAuditIdCol = "id"
 )
 
 var defaultOptions = OptionsSt{
+	Driver:            DriverPgxV4,
 	Timezone:          "Asia/Almaty",
 	MaxConns:          100,
 	MinConns:          5,
@@ -20,6 +27,7 @@ var defaultOptions = OptionsSt{
 	MaxConnIdleTime:   15 * time.Minute,
 	HealthCheckPeriod: 20 * time.Second,
 	FieldTag:          "db",
+	AuditTable:        "audit_log",
 }
 
 var (
@@ -0,0 +1,86 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rendau/dop/adapters/db"
+)
+
+type pgx4DriverSt struct {
+	pool *pgxpool.Pool
+}
+
+func newPgx4Driver(opts OptionsSt) (*pgx4DriverSt, error) {
+	cfg, err := pgxpool.ParseConfig(opts.Dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ConnConfig.RuntimeParams["timezone"] = opts.Timezone
+	cfg.MaxConns = opts.MaxConns
+	cfg.MinConns = opts.MinConns
+	cfg.MaxConnLifetime = opts.MaxConnLifetime
+	cfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	cfg.HealthCheckPeriod = opts.HealthCheckPeriod
+	cfg.LazyConnect = true
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgx4DriverSt{pool: pool}, nil
+}
+
+func (o *pgx4DriverSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.pool.Exec(ctx, sql, args...)
+	return err
+}
+
+func (o *pgx4DriverSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	return o.pool.Query(ctx, sql, args...)
+}
+
+func (o *pgx4DriverSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	return o.pool.QueryRow(ctx, sql, args...)
+}
+
+func (o *pgx4DriverSt) Begin(ctx context.Context) (driverTx, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx4TxSt{tx: tx}, nil
+}
+
+func (o *pgx4DriverSt) Close() {
+	o.pool.Close()
+}
+
+type pgx4TxSt struct {
+	tx pgx.Tx
+}
+
+func (o pgx4TxSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.tx.Exec(ctx, sql, args...)
+	return err
+}
+
+func (o pgx4TxSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	return o.tx.Query(ctx, sql, args...)
+}
+
+func (o pgx4TxSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	return o.tx.QueryRow(ctx, sql, args...)
+}
+
+func (o pgx4TxSt) Commit(ctx context.Context) error {
+	return o.tx.Commit(ctx)
+}
+
+func (o pgx4TxSt) Rollback(ctx context.Context) error {
+	return o.tx.Rollback(ctx)
+}
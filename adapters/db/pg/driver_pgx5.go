@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rendau/dop/adapters/db"
+)
+
+type pgx5DriverSt struct {
+	pool *pgxpool.Pool
+}
+
+func newPgx5Driver(opts OptionsSt) (*pgx5DriverSt, error) {
+	cfg, err := pgxpool.ParseConfig(opts.Dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ConnConfig.RuntimeParams["timezone"] = opts.Timezone
+	cfg.MaxConns = opts.MaxConns
+	cfg.MinConns = opts.MinConns
+	cfg.MaxConnLifetime = opts.MaxConnLifetime
+	cfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	cfg.HealthCheckPeriod = opts.HealthCheckPeriod
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgx5DriverSt{pool: pool}, nil
+}
+
+func (o *pgx5DriverSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.pool.Exec(ctx, sql, args...)
+	return err
+}
+
+func (o *pgx5DriverSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	return o.pool.Query(ctx, sql, args...)
+}
+
+func (o *pgx5DriverSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	return o.pool.QueryRow(ctx, sql, args...)
+}
+
+func (o *pgx5DriverSt) Begin(ctx context.Context) (driverTx, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx5TxSt{tx: tx}, nil
+}
+
+func (o *pgx5DriverSt) Close() {
+	o.pool.Close()
+}
+
+type pgx5TxSt struct {
+	tx pgx.Tx
+}
+
+func (o pgx5TxSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.tx.Exec(ctx, sql, args...)
+	return err
+}
+
+func (o pgx5TxSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	return o.tx.Query(ctx, sql, args...)
+}
+
+func (o pgx5TxSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	return o.tx.QueryRow(ctx, sql, args...)
+}
+
+func (o pgx5TxSt) Commit(ctx context.Context) error {
+	return o.tx.Commit(ctx)
+}
+
+func (o pgx5TxSt) Rollback(ctx context.Context) error {
+	return o.tx.Rollback(ctx)
+}
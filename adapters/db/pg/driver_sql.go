@@ -0,0 +1,249 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/rendau/dop/adapters/db"
+)
+
+type sqlDriverSt struct {
+	con *sql.DB
+}
+
+func newSqlDriver(opts OptionsSt) (*sqlDriverSt, error) {
+	cfg, err := pgx.ParseConfig(opts.Dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.RuntimeParams["timezone"] = opts.Timezone
+
+	con := stdlib.OpenDB(*cfg)
+	con.SetMaxOpenConns(int(opts.MaxConns))
+	con.SetMaxIdleConns(int(opts.MinConns))
+	con.SetConnMaxLifetime(opts.MaxConnLifetime)
+	con.SetConnMaxIdleTime(opts.MaxConnIdleTime)
+
+	return &sqlDriverSt{con: con}, nil
+}
+
+func (o *sqlDriverSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.con.ExecContext(ctx, sql, args...)
+	return err
+}
+
+func (o *sqlDriverSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	rows, err := o.con.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlRowsSt{Rows: rows}, nil
+}
+
+func (o *sqlDriverSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	rows, err := o.con.QueryContext(ctx, sql, args...)
+
+	return &sqlRowSt{rows: rows, err: err}
+}
+
+func (o *sqlDriverSt) Begin(ctx context.Context) (driverTx, error) {
+	tx, err := o.con.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlTxSt{tx: tx}, nil
+}
+
+func (o *sqlDriverSt) Close() {
+	_ = o.con.Close()
+}
+
+type sqlTxSt struct {
+	tx *sql.Tx
+}
+
+func (o sqlTxSt) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.tx.ExecContext(ctx, sql, args...)
+	return err
+}
+
+func (o sqlTxSt) Query(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
+	rows, err := o.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlRowsSt{Rows: rows}, nil
+}
+
+func (o sqlTxSt) QueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
+	rows, err := o.tx.QueryContext(ctx, sql, args...)
+
+	return &sqlRowSt{rows: rows, err: err}
+}
+
+func (o sqlTxSt) Commit(_ context.Context) error {
+	return o.tx.Commit()
+}
+
+func (o sqlTxSt) Rollback(_ context.Context) error {
+	return o.tx.Rollback()
+}
+
+// sqlRowsSt - database/sql can not scan arrays, json and composite types,
+// such destinations are decoded by pgx types using type of column
+type sqlRowsSt struct {
+	*sql.Rows
+
+	typeMap  *pgtype.Map
+	colTypes []*sql.ColumnType
+}
+
+func (o *sqlRowsSt) Close() {
+	_ = o.Rows.Close()
+}
+
+func (o *sqlRowsSt) Scan(dest ...any) error {
+	var err error
+
+	copied := false
+
+	for i, d := range dest {
+		if sqlScanNative(d) {
+			continue
+		}
+
+		if o.colTypes == nil {
+			o.colTypes, err = o.Rows.ColumnTypes()
+			if err != nil {
+				return err
+			}
+			o.typeMap = pgtype.NewMap()
+		}
+
+		if i >= len(o.colTypes) {
+			break
+		}
+
+		oid, err := sqlColumnOid(o.typeMap, o.colTypes[i])
+		if err != nil {
+			return err
+		}
+
+		if !copied { // not modify args of caller
+			dest = append([]any{}, dest...)
+			copied = true
+		}
+
+		dest[i] = &sqlPgScannerSt{typeMap: o.typeMap, oid: oid, dest: d}
+	}
+
+	return o.Rows.Scan(dest...)
+}
+
+// sqlColumnOid - stdlib reports type name of column, or oid if type is not registered
+func sqlColumnOid(typeMap *pgtype.Map, ct *sql.ColumnType) (uint32, error) {
+	name := ct.DatabaseTypeName()
+
+	if t, ok := typeMap.TypeForName(strings.ToLower(name)); ok {
+		return t.OID, nil
+	}
+
+	if oid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(oid), nil
+	}
+
+	return 0, fmt.Errorf("unknown type of column %q: %s", ct.Name(), name)
+}
+
+type sqlRowSt struct {
+	rows *sql.Rows
+	err  error
+}
+
+func (o *sqlRowSt) Scan(dest ...any) error {
+	if o.err != nil {
+		return o.err
+	}
+
+	rows := &sqlRowsSt{Rows: o.rows}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+
+	err := rows.Scan(dest...)
+	if err != nil {
+		return err
+	}
+
+	return o.rows.Close()
+}
+
+type sqlPgScannerSt struct {
+	typeMap *pgtype.Map
+	oid     uint32
+	dest    any
+}
+
+func (o *sqlPgScannerSt) Scan(src any) error {
+	var buf []byte
+
+	switch v := src.(type) {
+	case nil:
+	case string:
+		buf = []byte(v)
+	case []byte:
+		buf = v
+	default:
+		buf = []byte(fmt.Sprint(v))
+	}
+
+	return o.typeMap.Scan(o.oid, pgtype.TextFormatCode, buf, o.dest)
+}
+
+var (
+	sqlScannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// sqlScanNative - destination is supported by database/sql
+func sqlScanNative(dest any) bool {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return true
+	}
+
+	for t.Kind() == reflect.Pointer {
+		if t.Implements(sqlScannerType) {
+			return true
+		}
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Map, reflect.Array:
+		return false
+	case reflect.Struct:
+		return t == timeType
+	}
+
+	return true
+}
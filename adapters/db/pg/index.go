@@ -1,8 +1,10 @@
 package pg
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"reflect"
 	"strconv"
@@ -11,9 +13,12 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/jackc/pgx/v4/stdlib" // driver
+	pgx5 "github.com/jackc/pgx/v5"
 	"github.com/rendau/dop/adapters/db"
 	"github.com/rendau/dop/adapters/logger"
 	"github.com/rendau/dop/dopErrs"
+	"github.com/rendau/dop/dopTools"
+	"github.com/rendau/dop/dopTypes"
 )
 
 type St struct {
@@ -21,45 +26,58 @@ type St struct {
 	lg    logger.WarnAndError
 
 	opts OptionsSt
-	Con  *pgxpool.Pool
+	drv  driver
+
+	// Con - underlying pool, set only for DriverPgxV4 (nil for other drivers), use Conn for driver-neutral access
+	Con *pgxpool.Pool
 }
 
 func New(debug bool, lg logger.WarnAndError, opts OptionsSt) (*St, error) {
+	var err error
+
 	opts.mergeWithDefaults()
 
-	cfg, err := pgxpool.ParseConfig(opts.Dsn)
-	if err != nil {
-		lg.Errorw("Fail to create config", err, "opts", opts)
-		return nil, err
+	res := &St{
+		debug: debug,
+		lg:    lg,
+		opts:  opts,
 	}
 
-	cfg.ConnConfig.RuntimeParams["timezone"] = opts.Timezone
-	cfg.MaxConns = opts.MaxConns
-	cfg.MinConns = opts.MinConns
-	cfg.MaxConnLifetime = opts.MaxConnLifetime
-	cfg.MaxConnIdleTime = opts.MaxConnIdleTime
-	cfg.HealthCheckPeriod = opts.HealthCheckPeriod
-	cfg.LazyConnect = true
-
-	dbPool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	switch opts.Driver {
+	case DriverPgxV4:
+		var drv *pgx4DriverSt
+		if drv, err = newPgx4Driver(opts); err == nil {
+			res.drv, res.Con = drv, drv.pool
+		}
+	case DriverPgxV5:
+		res.drv, err = newPgx5Driver(opts)
+	case DriverSql:
+		res.drv, err = newSqlDriver(opts)
+	default:
+		err = errors.New("unknown driver: " + opts.Driver)
+	}
 	if err != nil {
-		lg.Errorw(ErrPrefix+": Fail to connect to db", err)
+		lg.Errorw(ErrPrefix+": Fail to connect to db", err, "driver", opts.Driver)
 		return nil, err
 	}
 
-	return &St{
-		debug: debug,
-		lg:    lg,
-		opts:  opts,
-		Con:   dbPool,
-	}, nil
+	return res, nil
+}
+
+func (d *St) Close() {
+	d.drv.Close()
+}
+
+// Conn - driver-neutral connection, it is transaction of ctx if there is one
+func (d *St) Conn(ctx context.Context) db.RDBConSt {
+	return d.getCon(ctx)
 }
 
 func (d *St) getCon(ctx context.Context) db.RDBConSt {
 	if tx := d.getContextTransaction(ctx); tx != nil {
 		return tx.tx
 	}
-	return d.Con
+	return d.drv
 }
 
 // transaction
@@ -82,7 +100,7 @@ func (d *St) contextWithTransaction(ctx context.Context) (context.Context, error
 		return ctx, nil
 	}
 
-	tx, err := d.Con.Begin(ctx)
+	tx, err := d.drv.Begin(ctx)
 	if err != nil {
 		return ctx, d.HErr(err)
 	}
@@ -135,7 +153,7 @@ func (d *St) RenewContextTransaction(ctx context.Context) error {
 		}
 	}
 
-	tx.tx, err = d.Con.Begin(ctx)
+	tx.tx, err = d.drv.Begin(ctx)
 	if err != nil {
 		return d.HErr(err)
 	}
@@ -175,17 +193,17 @@ func (d *St) TransactionAddAsyncCallback(ctx context.Context, f func()) {
 // query
 
 func (d *St) DbExec(ctx context.Context, sql string, args ...any) error {
-	_, err := d.getCon(ctx).Exec(ctx, sql, args...)
+	err := d.getCon(ctx).Exec(ctx, sql, args...)
 	return d.HErr(err)
 }
 
 func (d *St) DbQuery(ctx context.Context, sql string, args ...any) (db.RDBRows, error) {
 	rows, err := d.getCon(ctx).Query(ctx, sql, args...)
-	return rowsSt{Rows: rows, db: d}, d.HErr(err)
+	return rowsSt{RDBRows: rows, db: d}, d.HErr(err)
 }
 
 func (d *St) DbQueryRow(ctx context.Context, sql string, args ...any) db.RDBRow {
-	return rowSt{Row: d.getCon(ctx).QueryRow(ctx, sql, args...), db: d}
+	return rowSt{RDBRow: d.getCon(ctx).QueryRow(ctx, sql, args...), db: d}
 }
 
 func (d *St) queryRebindNamed(sql string, argMap map[string]any) (string, []any) {
@@ -212,26 +230,26 @@ func (d *St) queryRebindNamed(sql string, argMap map[string]any) (string, []any)
 
 func (d *St) DbExecM(ctx context.Context, sql string, argMap map[string]any) error {
 	rbSql, args := d.queryRebindNamed(sql, argMap)
-	_, err := d.getCon(ctx).Exec(ctx, rbSql, args...)
+	err := d.getCon(ctx).Exec(ctx, rbSql, args...)
 	return d.HErr(err)
 }
 
 func (d *St) DbQueryM(ctx context.Context, sql string, argMap map[string]any) (db.RDBRows, error) {
 	rbSql, args := d.queryRebindNamed(sql, argMap)
 	rows, err := d.getCon(ctx).Query(ctx, rbSql, args...)
-	return rowsSt{Rows: rows, db: d}, d.HErr(err)
+	return rowsSt{RDBRows: rows, db: d}, d.HErr(err)
 }
 
 func (d *St) DbQueryRowM(ctx context.Context, sql string, argMap map[string]any) db.RDBRow {
 	rbSql, args := d.queryRebindNamed(sql, argMap)
-	return rowSt{Row: d.getCon(ctx).QueryRow(ctx, rbSql, args...), db: d}
+	return rowSt{RDBRow: d.getCon(ctx).QueryRow(ctx, rbSql, args...), db: d}
 }
 
 func (d *St) HErr(err error) error {
 	switch {
 	case err == nil:
 		return nil
-	case errors.Is(err, pgx.ErrNoRows), errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, pgx.ErrNoRows), errors.Is(err, pgx5.ErrNoRows), errors.Is(err, sql.ErrNoRows):
 		err = dopErrs.NoRows
 	default:
 		d.lg.Errorw(ErrPrefix, err)
@@ -243,6 +261,12 @@ func (d *St) HErr(err error) error {
 // helpers
 
 func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
+	tCount, _, err := d.hfList(ctx, ops, false)
+	return tCount, err
+}
+
+// hfList - if probeNext is true, fetches one extra row to detect next page existence
+func (d *St) hfList(ctx context.Context, ops db.RDBListOptions, probeNext bool) (int64, bool, error) {
 	var tCount int64
 
 	qWhere := d.HfOptionalWhere(ops.Conds)
@@ -261,24 +285,24 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 			` from `+strings.Join(ops.Tables, " ")+
 			qWhere, ops.Args).Scan(&tCount)
 		if err != nil {
-			return 0, d.HErr(err)
+			return 0, false, d.HErr(err)
 		}
 
 		if ops.LPars.OnlyCount {
-			return tCount, nil
+			return tCount, false, nil
 		}
 	}
 
 	dstV := reflect.ValueOf(ops.Dst)
 
 	if dstV.Kind() != reflect.Pointer {
-		return 0, d.HErr(errors.New("dst must be pointer to slice"))
+		return 0, false, d.HErr(errors.New("dst must be pointer to slice"))
 	}
 
 	dstV = reflect.Indirect(dstV)
 
 	if dstV.Kind() != reflect.Slice {
-		return 0, d.HErr(errors.New("dst must be pointer to slice"))
+		return 0, false, d.HErr(errors.New("dst must be pointer to slice"))
 	}
 
 	elemBaseType := dstV.Type().Elem()
@@ -293,13 +317,15 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 	}
 
 	if elemType.Kind() != reflect.Struct {
-		return 0, d.HErr(errors.New("dst element type must struct"))
+		return 0, false, d.HErr(errors.New("dst element type must struct"))
 	}
 
 	if dstV.IsNil() {
 		dstV.Set(reflect.MakeSlice(reflect.SliceOf(elemBaseType), 0, 10))
 	}
 
+	dstStartLen := dstV.Len()
+
 	elemFieldNameMap := d.hfGetStructFieldMap(reflect.VisibleFields(elemType))
 
 	// generate columns
@@ -322,7 +348,11 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 
 	if ops.LPars.PageSize > 0 {
 		qOffset = ` offset ` + strconv.FormatInt(ops.LPars.Page*ops.LPars.PageSize, 10)
-		qLimit = ` limit ` + strconv.FormatInt(ops.LPars.PageSize, 10)
+		if probeNext {
+			qLimit = ` limit ` + strconv.FormatInt(ops.LPars.PageSize+1, 10)
+		} else {
+			qLimit = ` limit ` + strconv.FormatInt(ops.LPars.PageSize, 10)
+		}
 	}
 
 	query := `select ` + distinct + strings.Join(colExps, ",") +
@@ -336,7 +366,7 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 
 	rows, err := d.DbQueryM(ctx, query, ops.Args)
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
 	defer rows.Close()
 
@@ -354,7 +384,7 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 
 		err = rows.Scan(scanFields...)
 		if err != nil {
-			return 0, d.HErr(err)
+			return 0, false, d.HErr(err)
 		}
 
 		if elemIsPtr {
@@ -364,10 +394,91 @@ func (d *St) HfList(ctx context.Context, ops db.RDBListOptions) (int64, error) {
 		}
 	}
 	if err = rows.Err(); err != nil {
-		return 0, d.HErr(err)
+		return 0, false, d.HErr(err)
+	}
+
+	hasNext := false
+
+	if probeNext && ops.LPars.PageSize > 0 && int64(dstV.Len()-dstStartLen) > ops.LPars.PageSize {
+		dstV.Set(dstV.Slice(0, dstStartLen+int(ops.LPars.PageSize)))
+		hasNext = true
+	}
+
+	return tCount, hasNext, nil
+}
+
+func (d *St) HfListPaginated(ctx context.Context, ops db.RDBListRepOptions) (*dopTypes.PaginatedListRep, error) {
+	err := dopTools.RequirePageSize(ops.LPars, ops.MaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.hfCheckListCols(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	tCount, hasNext, err := d.hfList(ctx, ops.RDBListOptions, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dopTypes.PaginatedListRep{
+		Page:       ops.LPars.Page,
+		PageSize:   ops.LPars.PageSize,
+		TotalCount: tCount,
+		HasNext:    hasNext,
+		Results:    reflect.Indirect(reflect.ValueOf(ops.Dst)).Interface(),
+	}, nil
+}
+
+func (d *St) HfListRep(ctx context.Context, ops db.RDBListRepOptions) (*dopTypes.ListRep, error) {
+	if ops.MaxPageSize > 0 && ops.LPars.PageSize > ops.MaxPageSize {
+		return nil, dopErrs.IncorrectPageSize
+	}
+
+	err := d.hfCheckListCols(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.HfList(ctx, ops.RDBListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dopTypes.ListRep{
+		Results: reflect.Indirect(reflect.ValueOf(ops.Dst)).Interface(),
+	}, nil
+}
+
+func (d *St) hfCheckListCols(ops db.RDBListRepOptions) error {
+	if len(ops.LPars.Cols) == 0 {
+		return nil
 	}
 
-	return tCount, nil
+	elemType := reflect.TypeOf(ops.Dst)
+	for elemType != nil && (elemType.Kind() == reflect.Pointer || elemType.Kind() == reflect.Slice) {
+		elemType = elemType.Elem()
+	}
+
+	if elemType == nil || elemType.Kind() != reflect.Struct {
+		return d.HErr(errors.New("dst element type must struct"))
+	}
+
+	elemFieldNameMap := d.hfGetStructFieldMap(reflect.VisibleFields(elemType))
+
+	for _, cn := range ops.LPars.Cols {
+		if _, ok := elemFieldNameMap[cn]; !ok {
+			return dopErrs.ErrWithDesc{Err: dopErrs.BadColumnName, Desc: cn}
+		}
+
+		if len(ops.AllowedCols) > 0 && !dopTools.SliceHasValue(ops.AllowedCols, cn) {
+			return dopErrs.ErrWithDesc{Err: dopErrs.BadColumnName, Desc: cn}
+		}
+	}
+
+	return nil
 }
 
 func (d *St) hfGenerateColumns(stFields map[string]string, ops db.RDBListOptions) ([]string, []string) {
@@ -499,6 +610,22 @@ func (d *St) hfGetStructFieldMap(fields []reflect.StructField) map[string]string
 }
 
 func (d *St) HfCreate(ctx context.Context, ops db.RDBCreateOptions) error {
+	if ops.Audit {
+		return d.hfAuditTransactionFn(ctx, func(ctx context.Context) error {
+			return d.hfAuditCreate(ctx, ops)
+		})
+	}
+
+	query, args, _ := d.hfCreateQuery(ops)
+
+	if ops.RetCol != "" && ops.RetV != nil {
+		return d.DbQueryRow(ctx, query+" returning "+ops.RetCol, args...).Scan(ops.RetV)
+	} else {
+		return d.DbExec(ctx, query, args...)
+	}
+}
+
+func (d *St) hfCreateQuery(ops db.RDBCreateOptions) (string, []any, []string) {
 	fMap, _ := d.HfGetCUFields(ops.Obj)
 
 	fields := make([]string, len(fMap))
@@ -518,14 +645,20 @@ func (d *St) HfCreate(ctx context.Context, ops db.RDBCreateOptions) error {
         values (` + strings.Join(values, ",") + `)
 	`
 
-	if ops.RetCol != "" && ops.RetV != nil {
-		return d.DbQueryRow(ctx, query+" returning "+ops.RetCol, args...).Scan(ops.RetV)
-	} else {
-		return d.DbExec(ctx, query, args...)
-	}
+	return query, args, fields
 }
 
 func (d *St) HfUpdate(ctx context.Context, ops db.RDBUpdateOptions) error {
+	if ops.Audit {
+		return d.hfAuditTransactionFn(ctx, func(ctx context.Context) error {
+			return d.hfAuditUpdate(ctx, ops)
+		})
+	}
+
+	return d.hfUpdate(ctx, ops)
+}
+
+func (d *St) hfUpdate(ctx context.Context, ops db.RDBUpdateOptions) error {
 	fMap, mergeFlagMap := d.HfGetCUFields(ops.Obj)
 
 	fields := make([]string, 0, len(fMap))
@@ -619,5 +752,453 @@ func (d *St) HfOptionalWhere(conds []string) string {
 }
 
 func (d *St) HfDelete(ctx context.Context, ops db.RDBDeleteOptions) error {
+	if ops.Audit {
+		return d.hfAuditTransactionFn(ctx, func(ctx context.Context) error {
+			return d.hfAuditDelete(ctx, ops)
+		})
+	}
+
 	return d.DbExecM(ctx, `delete from `+ops.Table+d.HfOptionalWhere(ops.Conds), ops.Args)
 }
+
+func (d *St) HfChartByTime(ctx context.Context, ops db.RDBChartByTimeOptions) ([]dopTypes.ChartVByTimeSt, error) {
+	ops.GroupExpr = ""
+
+	series, err := d.HfChartByTimeGrouped(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(series) == 0 {
+		return []dopTypes.ChartVByTimeSt{}, nil
+	}
+
+	return series[0].Values, nil
+}
+
+func (d *St) HfChartByTimeGrouped(ctx context.Context, ops db.RDBChartByTimeOptions) ([]dopTypes.ChartSeriesByTimeSt, error) {
+	switch ops.Interval {
+	case db.ChartIntervalHour, db.ChartIntervalDay, db.ChartIntervalWeek, db.ChartIntervalMonth:
+	default:
+		return nil, dopErrs.ErrWithDesc{
+			Err:  dopErrs.BadQueryParams,
+			Desc: "bad chart interval: " + ops.Interval,
+		}
+	}
+
+	if ops.TsCol == "" {
+		return nil, dopErrs.ErrWithDesc{
+			Err:  dopErrs.BadQueryParams,
+			Desc: "chart ts-col is required",
+		}
+	}
+
+	aggExpr := ops.AggExpr
+	if aggExpr == "" {
+		aggExpr = "count(*)"
+	}
+
+	args := make(map[string]any, len(ops.Args)+3)
+	for k, v := range ops.Args {
+		args[k] = v
+	}
+
+	args["hf_chart_tz"] = ops.Timezone
+	if ops.Timezone == "" {
+		args["hf_chart_tz"] = d.opts.Timezone
+	}
+
+	tsExpr := `date_trunc('` + ops.Interval + `', ` + ops.TsCol + ` at time zone ${hf_chart_tz}::text)`
+	qFrom := ` from ` + strings.Join(ops.Tables, " ")
+	conds := append([]string{}, ops.Conds...)
+
+	// series bounds
+	var seriesGte, seriesLte string
+
+	if ops.Period.TsGTE != nil {
+		args["hf_chart_ts_gte"] = *ops.Period.TsGTE
+		conds = append(conds, ops.TsCol+` >= ${hf_chart_ts_gte}`)
+		seriesGte = `date_trunc('` + ops.Interval + `', ${hf_chart_ts_gte}::timestamptz at time zone ${hf_chart_tz}::text)`
+	} else {
+		seriesGte = `(select min(` + tsExpr + `)` + qFrom + d.HfOptionalWhere(conds) + `)`
+	}
+
+	if ops.Period.TsLTE != nil {
+		args["hf_chart_ts_lte"] = *ops.Period.TsLTE
+		conds = append(conds, ops.TsCol+` <= ${hf_chart_ts_lte}`)
+		seriesLte = `date_trunc('` + ops.Interval + `', ${hf_chart_ts_lte}::timestamptz at time zone ${hf_chart_tz}::text)`
+	} else {
+		seriesLte = `(select max(` + tsExpr + `)` + qFrom + d.HfOptionalWhere(conds) + `)`
+	}
+
+	// without group-expr single series is returned, gap-filled even if there is no data
+	groupExpr := `''`
+	qGroups := `select ''::text as g`
+	if ops.GroupExpr != "" {
+		groupExpr = `coalesce((` + ops.GroupExpr + `)::text, '')`
+		qGroups = `select distinct d.g from d`
+	}
+
+	query := `
+		with s as (
+			select generate_series(` + seriesGte + `, ` + seriesLte + `, '1 ` + ops.Interval + `'::interval) as ts
+		), d as (
+			select ` + tsExpr + ` as ts, ` + groupExpr + ` as g, (` + aggExpr + `) as v
+			` + qFrom + d.HfOptionalWhere(conds) + `
+			group by 1, 2
+		), g as (
+			` + qGroups + `
+		)
+		select g.g, s.ts at time zone ${hf_chart_tz}::text, coalesce(d.v, 0)::bigint
+		from g
+			cross join s
+			left join d on d.g = g.g and d.ts = s.ts
+		order by g.g, s.ts
+	`
+
+	rows, err := d.DbQueryM(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dopTypes.ChartSeriesByTimeSt, 0)
+
+	var key string
+	var item dopTypes.ChartVByTimeSt
+
+	for rows.Next() {
+		err = rows.Scan(&key, &item.Ts, &item.V)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result) == 0 || result[len(result)-1].Key != key {
+			result = append(result, dopTypes.ChartSeriesByTimeSt{
+				Key:    key,
+				Values: make([]dopTypes.ChartVByTimeSt, 0),
+			})
+		}
+
+		result[len(result)-1].Values = append(result[len(result)-1].Values, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// audit
+
+// hfAuditTransactionFn - runs f in transaction of context, begins and commits own one only if there is none
+func (d *St) hfAuditTransactionFn(ctx context.Context, f func(context.Context) error) error {
+	if ctx != nil && d.getContextTransaction(ctx) != nil {
+		return f(ctx)
+	}
+
+	return d.TransactionFn(ctx, f)
+}
+
+func (d *St) hfAuditCreate(ctx context.Context, ops db.RDBCreateOptions) error {
+	var err error
+
+	idCol := d.hfAuditIdCol(ops.AuditIdCol)
+
+	query, args, fields := d.hfCreateQuery(ops)
+
+	var entityId string
+
+	if ops.RetCol != "" && ops.RetV != nil {
+		err = d.DbQueryRow(ctx, query+" returning "+ops.RetCol+", "+idCol+"::text", args...).Scan(ops.RetV, &entityId)
+	} else {
+		err = d.DbQueryRow(ctx, query+" returning "+idCol+"::text", args...).Scan(&entityId)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := d.hfAuditSelectByIds(ctx, ops.Table, idCol, []string{entityId})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err = d.hfAuditWrite(ctx, ops.Table, row.id, db.AuditActionCreate, nil, hfAuditPickValues(row.values, fields))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *St) hfAuditUpdate(ctx context.Context, ops db.RDBUpdateOptions) error {
+	idCol := d.hfAuditIdCol(ops.AuditIdCol)
+
+	fMap, _ := d.HfGetCUFields(ops.Obj)
+	if len(fMap) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(fMap))
+	for k := range fMap {
+		fields = append(fields, k)
+	}
+
+	oldRows, err := d.hfAuditSelect(ctx, ops.Table, idCol, ops.Conds, ops.Args)
+	if err != nil {
+		return err
+	}
+
+	err = d.hfUpdate(ctx, ops)
+	if err != nil {
+		return err
+	}
+
+	if len(oldRows) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(oldRows))
+	for i, row := range oldRows {
+		ids[i] = row.id
+	}
+
+	newRows, err := d.hfAuditSelectByIds(ctx, ops.Table, idCol, ids)
+	if err != nil {
+		return err
+	}
+
+	newRowMap := make(map[string]map[string]json.RawMessage, len(newRows))
+	for _, row := range newRows {
+		newRowMap[row.id] = row.values
+	}
+
+	for _, oldRow := range oldRows {
+		newValues := newRowMap[oldRow.id]
+
+		changedFields := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if !bytes.Equal(oldRow.values[f], newValues[f]) {
+				changedFields = append(changedFields, f)
+			}
+		}
+
+		if len(changedFields) == 0 {
+			continue
+		}
+
+		err = d.hfAuditWrite(
+			ctx,
+			ops.Table,
+			oldRow.id,
+			db.AuditActionUpdate,
+			hfAuditPickValues(oldRow.values, changedFields),
+			hfAuditPickValues(newValues, changedFields),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *St) hfAuditDelete(ctx context.Context, ops db.RDBDeleteOptions) error {
+	idCol := d.hfAuditIdCol(ops.AuditIdCol)
+
+	oldRows, err := d.hfAuditSelect(ctx, ops.Table, idCol, ops.Conds, ops.Args)
+	if err != nil {
+		return err
+	}
+
+	err = d.DbExecM(ctx, `delete from `+ops.Table+d.HfOptionalWhere(ops.Conds), ops.Args)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range oldRows {
+		err = d.hfAuditWrite(ctx, ops.Table, row.id, db.AuditActionDelete, row.values, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *St) hfAuditIdCol(v string) string {
+	if v == "" {
+		return defaultAuditIdCol
+	}
+	return v
+}
+
+func (d *St) hfAuditSelectByIds(ctx context.Context, table, idCol string, ids []string) ([]*auditRowSt, error) {
+	// ids are converted back to type of idCol, so it is compared as is (with index)
+	cond := idCol + ` in (
+		select (jsonb_populate_record(null::` + table + `, jsonb_build_object('` + idCol + `', v))).` + idCol + `
+		from unnest(${hf_audit_ids}::text[]) v
+	)`
+
+	return d.hfAuditSelect(ctx, table, idCol, []string{cond}, map[string]any{
+		"hf_audit_ids": ids,
+	})
+}
+
+func (d *St) hfAuditSelect(ctx context.Context, table, idCol string, conds []string, args map[string]any) ([]*auditRowSt, error) {
+	rows, err := d.DbQueryM(ctx, `
+		select x.`+idCol+`::text, to_jsonb(x)
+		from (
+			select * from `+table+d.HfOptionalWhere(conds)+` for update
+		) x
+	`, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*auditRowSt, 0)
+
+	var rowRaw []byte
+
+	for rows.Next() {
+		row := &auditRowSt{}
+
+		err = rows.Scan(&row.id, &rowRaw)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(rowRaw, &row.values)
+		if err != nil {
+			return nil, d.HErr(err)
+		}
+
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func hfAuditPickValues(values map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	result := make(map[string]json.RawMessage, len(fields))
+
+	for _, f := range fields {
+		if v, ok := values[f]; ok {
+			result[f] = v
+		}
+	}
+
+	return result
+}
+
+func (d *St) hfAuditWrite(ctx context.Context, table, entityId, action string, oldValues, newValues map[string]json.RawMessage) error {
+	args := map[string]any{
+		"tbl":        table,
+		"entity_id":  entityId,
+		"action":     action,
+		"actor":      db.AuditActorFromContext(ctx),
+		"old_values": nil,
+		"new_values": nil,
+	}
+
+	if oldValues != nil {
+		raw, err := json.Marshal(oldValues)
+		if err != nil {
+			return d.HErr(err)
+		}
+		args["old_values"] = string(raw)
+	}
+
+	if newValues != nil {
+		raw, err := json.Marshal(newValues)
+		if err != nil {
+			return d.HErr(err)
+		}
+		args["new_values"] = string(raw)
+	}
+
+	return d.DbExecM(ctx, `
+		insert into `+d.opts.AuditTable+` (ts, tbl, entity_id, action, actor, old_values, new_values)
+		values (now(), ${tbl}, ${entity_id}, ${action}, ${actor}, ${old_values}::jsonb, ${new_values}::jsonb)
+	`, args)
+}
+
+func (d *St) HfAuditList(ctx context.Context, ops db.RDBAuditListOptions) (*dopTypes.PaginatedListRep, error) {
+	var err error
+
+	args := map[string]any{
+		"tbl":       ops.Table,
+		"entity_id": ops.EntityId,
+	}
+
+	qWhere := ` where tbl = ${tbl} and entity_id = ${entity_id}`
+
+	result := &dopTypes.PaginatedListRep{
+		Page:     ops.LPars.Page,
+		PageSize: ops.LPars.PageSize,
+	}
+
+	err = d.DbQueryRowM(ctx, `select count(*) from `+d.opts.AuditTable+qWhere, args).Scan(&result.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	qOffset := ``
+	qLimit := ``
+
+	if ops.LPars.PageSize > 0 {
+		qOffset = ` offset ` + strconv.FormatInt(ops.LPars.Page*ops.LPars.PageSize, 10)
+		qLimit = ` limit ` + strconv.FormatInt(ops.LPars.PageSize, 10)
+	}
+
+	rows, err := d.DbQueryM(ctx, `
+		select id, ts, tbl, entity_id, action, actor, old_values, new_values
+		from `+d.opts.AuditTable+qWhere+`
+		order by ts desc, id desc`+
+		qOffset+
+		qLimit, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]*db.RDBAuditRecordSt, 0)
+
+	var oldRaw, newRaw []byte
+
+	for rows.Next() {
+		rec := &db.RDBAuditRecordSt{}
+
+		err = rows.Scan(&rec.Id, &rec.Ts, &rec.Table, &rec.EntityId, &rec.Action, &rec.Actor, &oldRaw, &newRaw)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(oldRaw) > 0 {
+			if err = json.Unmarshal(oldRaw, &rec.OldValues); err != nil {
+				return nil, d.HErr(err)
+			}
+		}
+
+		if len(newRaw) > 0 {
+			if err = json.Unmarshal(newRaw, &rec.NewValues); err != nil {
+				return nil, d.HErr(err)
+			}
+		}
+
+		records = append(records, rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Results = records
+
+	return result, nil
+}
@@ -1,15 +1,17 @@
 package pg
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 
-	"github.com/jackc/pgx/v4"
 	"github.com/rendau/dop/adapters/db"
 )
 
 // Options
 
 type OptionsSt struct {
+	Driver            string
 	Dsn               string
 	Timezone          string
 	MaxConns          int32
@@ -18,9 +20,16 @@ type OptionsSt struct {
 	MaxConnIdleTime   time.Duration
 	HealthCheckPeriod time.Duration
 	FieldTag          string
+
+	// AuditTable - table for audit records of Hf-writes with "Audit" flag. Expected columns:
+	// id bigserial, ts timestamptz, tbl text, entity_id text, action text, actor text, old_values jsonb, new_values jsonb
+	AuditTable string
 }
 
 func (o *OptionsSt) mergeWithDefaults() {
+	if o.Driver == "" {
+		o.Driver = defaultOptions.Driver
+	}
 	if o.Timezone == "" {
 		o.Timezone = defaultOptions.Timezone
 	}
@@ -42,31 +51,51 @@ func (o *OptionsSt) mergeWithDefaults() {
 	if o.FieldTag == "" {
 		o.FieldTag = defaultOptions.FieldTag
 	}
+	if o.AuditTable == "" {
+		o.AuditTable = defaultOptions.AuditTable
+	}
+}
+
+type driver interface {
+	db.RDBConSt
+	Begin(ctx context.Context) (driverTx, error)
+	Close()
+}
+
+type driverTx interface {
+	db.RDBConSt
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
 }
 
 type txContainerSt struct {
-	tx             pgx.Tx
+	tx             driverTx
 	asyncCallbacks []func()
 }
 
+type auditRowSt struct {
+	id     string
+	values map[string]json.RawMessage
+}
+
 type rowsSt struct {
-	pgx.Rows
+	db.RDBRows
 	db db.RDBConnection
 }
 
 func (o rowsSt) Err() error {
-	return o.db.HErr(o.Rows.Err())
+	return o.db.HErr(o.RDBRows.Err())
 }
 
 func (o rowsSt) Scan(dest ...any) error {
-	return o.db.HErr(o.Rows.Scan(dest...))
+	return o.db.HErr(o.RDBRows.Scan(dest...))
 }
 
 type rowSt struct {
-	pgx.Row
+	db.RDBRow
 	db db.RDBConnection
 }
 
 func (o rowSt) Scan(dest ...any) error {
-	return o.db.HErr(o.Row.Scan(dest...))
+	return o.db.HErr(o.RDBRow.Scan(dest...))
 }
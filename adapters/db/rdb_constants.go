@@ -0,0 +1,16 @@
+package db
+
+type auditActorCtxKeyT int8
+
+const (
+	ChartIntervalHour  = "hour"
+	ChartIntervalDay   = "day"
+	ChartIntervalWeek  = "week"
+	ChartIntervalMonth = "month"
+
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	auditActorCtxKey = auditActorCtxKeyT(1)
+)
@@ -3,8 +3,7 @@ package db
 import (
 	"context"
 
-	"github.com/jackc/pgconn"
-	"github.com/jackc/pgx/v4"
+	"github.com/rendau/dop/dopTypes"
 )
 
 type RDBFull interface {
@@ -31,6 +30,8 @@ type RDBConnectionWithHelpers interface {
 	RDBConnection
 
 	HfList(ctx context.Context, ops RDBListOptions) (int64, error)
+	HfListPaginated(ctx context.Context, ops RDBListRepOptions) (*dopTypes.PaginatedListRep, error)
+	HfListRep(ctx context.Context, ops RDBListRepOptions) (*dopTypes.ListRep, error)
 	HfGenerateSort(rNames []string, allowed map[string]string) []string
 	HfGet(ctx context.Context, ops RDBGetOptions) error
 	HfCreate(ctx context.Context, ops RDBCreateOptions) error
@@ -38,6 +39,9 @@ type RDBConnectionWithHelpers interface {
 	HfGetCUFields(obj any) (map[string]any, map[string]bool)
 	HfOptionalWhere(conds []string) string
 	HfDelete(ctx context.Context, ops RDBDeleteOptions) error
+	HfChartByTime(ctx context.Context, ops RDBChartByTimeOptions) ([]dopTypes.ChartVByTimeSt, error)
+	HfChartByTimeGrouped(ctx context.Context, ops RDBChartByTimeOptions) ([]dopTypes.ChartSeriesByTimeSt, error)
+	HfAuditList(ctx context.Context, ops RDBAuditListOptions) (*dopTypes.PaginatedListRep, error)
 }
 
 type RDBContextTransaction interface {
@@ -58,7 +62,7 @@ type RDBRow interface {
 }
 
 type RDBConSt interface {
-	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
-	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
-	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) error
+	Query(ctx context.Context, sql string, args ...any) (RDBRows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) RDBRow
 }
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/rendau/dop/dopTypes"
 )
 
@@ -18,6 +20,12 @@ type RDBListOptions struct {
 	AllowedSortNames map[string]string
 }
 
+type RDBListRepOptions struct {
+	RDBListOptions
+	MaxPageSize int64
+	AllowedCols []string
+}
+
 type RDBGetOptions struct {
 	Dst      any
 	Tables   []string
@@ -27,21 +35,56 @@ type RDBGetOptions struct {
 }
 
 type RDBCreateOptions struct {
-	Table  string
-	Obj    any
-	RetCol string
-	RetV   any
+	Table      string
+	Obj        any
+	RetCol     string
+	RetV       any
+	Audit      bool
+	AuditIdCol string
 }
 
 type RDBUpdateOptions struct {
-	Table string
-	Obj   any
-	Conds []string
-	Args  map[string]any
+	Table      string
+	Obj        any
+	Conds      []string
+	Args       map[string]any
+	Audit      bool
+	AuditIdCol string
 }
 
 type RDBDeleteOptions struct {
-	Table string
-	Conds []string
-	Args  map[string]any
+	Table      string
+	Conds      []string
+	Args       map[string]any
+	Audit      bool
+	AuditIdCol string
+}
+
+type RDBChartByTimeOptions struct {
+	Tables    []string
+	Conds     []string
+	Args      map[string]any
+	TsCol     string
+	Period    dopTypes.PeriodPars
+	Interval  string
+	AggExpr   string
+	GroupExpr string
+	Timezone  string
+}
+
+type RDBAuditListOptions struct {
+	Table    string
+	EntityId string
+	LPars    dopTypes.ListParams
+}
+
+type RDBAuditRecordSt struct {
+	Id        int64          `json:"id"`
+	Ts        time.Time      `json:"ts"`
+	Table     string         `json:"table"`
+	EntityId  string         `json:"entity_id"`
+	Action    string         `json:"action"`
+	Actor     string         `json:"actor"`
+	OldValues map[string]any `json:"old_values"`
+	NewValues map[string]any `json:"new_values"`
 }
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"context"
+)
+
+func ContextWithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorCtxKey, actor)
+}
+
+func AuditActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(auditActorCtxKey).(string); ok {
+		return actor
+	}
+	return ""
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/rendau/dop/adapters/logger"
+	"github.com/rendau/dop/adapters/trace"
 	"github.com/rendau/dop/dopErrs"
 	"github.com/rendau/dop/dopTypes"
 	cors "github.com/rs/cors/wrapper/gin"
@@ -19,6 +20,9 @@ const (
 	ReadHeaderTimeout = 10 * time.Second
 	ReadTimeout       = 2 * time.Minute
 	MaxHeaderBytes    = 300 * 1024
+
+	// TraceKey - key of trace.St in gin context
+	TraceKey = "dop_trace"
 )
 
 type St struct {
@@ -180,8 +184,10 @@ func MwRecovery(lg logger.WarnAndError, handler func(*gin.Context, error)) gin.H
 				lg.Errorw(
 					"Error in httpc handler",
 					err,
-					"method", c.Request.Method,
-					"path", c.Request.URL.String(),
+					append([]any{
+						"method", c.Request.Method,
+						"path", c.Request.URL.String(),
+					}, trace.LogArgs(c.Request.Context())...)...,
 				)
 
 				c.AbortWithStatus(http.StatusInternalServerError)
@@ -192,6 +198,42 @@ func MwRecovery(lg logger.WarnAndError, handler func(*gin.Context, error)) gin.H
 	}
 }
 
+// MwTrace - accepts or generates X-Request-Id and traceparent, stores them in gin and request contexts.
+// X-Request-Id is returned in response, pass c.Request.Context() to httpc to propagate them
+func MwTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := trace.FromHeaders(c.Request.Header)
+
+		c.Request = c.Request.WithContext(trace.ContextWithTrace(c.Request.Context(), t))
+		c.Set(TraceKey, t)
+
+		c.Header(trace.HeaderRequestId, t.RequestId)
+
+		c.Next()
+	}
+}
+
+func GetTrace(c *gin.Context) (trace.St, bool) {
+	if v, ok := c.Get(TraceKey); ok {
+		if t, ok := v.(trace.St); ok {
+			return t, true
+		}
+	}
+
+	return trace.FromContext(c.Request.Context())
+}
+
+func GetRequestId(c *gin.Context) string {
+	t, _ := GetTrace(c)
+
+	return t.RequestId
+}
+
+// Logger - returns lg which adds request id and trace of request to all calls
+func Logger(c *gin.Context, lg logger.Lite) logger.Lite {
+	return trace.Logger(c.Request.Context(), lg)
+}
+
 func MwCors() gin.HandlerFunc {
 	return cors.New(cors.Options{
 		AllowOriginFunc: func(origin string) bool { return true },
@@ -0,0 +1,104 @@
+package https
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rendau/dop/adapters/client/httpc"
+	"github.com/rendau/dop/adapters/client/httpc/httpclient"
+	"github.com/rendau/dop/adapters/trace"
+	"github.com/stretchr/testify/require"
+)
+
+type testLoggerSt struct {
+	args [][]any
+	mu   sync.Mutex
+}
+
+func (l *testLoggerSt) Infow(msg string, args ...any) {}
+
+func (l *testLoggerSt) Warnw(msg string, args ...any) {}
+
+func (l *testLoggerSt) Errorw(msg string, err any, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.args = append(l.args, args)
+}
+
+func TestMwTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstreamHeaders := make(chan http.Header, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeaders <- r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	lg := &testLoggerSt{}
+
+	hc := httpclient.New(lg, &httpc.OptionsSt{
+		Client: http.DefaultClient,
+		Uri:    upstream.URL,
+	})
+
+	r := gin.New()
+	r.Use(MwTrace(), MwRecovery(lg, nil))
+
+	var handlerTrace trace.St
+
+	r.GET("/call", func(c *gin.Context) {
+		handlerTrace, _ = GetTrace(c)
+
+		_, err := hc.SendCtx(c.Request.Context(), &httpc.OptionsSt{Uri: "data"})
+		Error(c, err)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		Error(c, errors.New("internal"))
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// incoming ids are accepted and propagated
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/call", nil)
+	require.Nil(t, err)
+	req.Header.Set(trace.HeaderRequestId, "req-1")
+	req.Header.Set(trace.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rep, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	_ = rep.Body.Close()
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+	require.Equal(t, "req-1", rep.Header.Get(trace.HeaderRequestId))
+
+	require.Equal(t, "req-1", handlerTrace.RequestId)
+	require.Equal(t, "00f067aa0ba902b7", handlerTrace.ParentSpanId)
+
+	h := <-upstreamHeaders
+	require.Equal(t, "req-1", h.Get(trace.HeaderRequestId))
+
+	traceId, parentId, _, ok := trace.ParseTraceparent(h.Get(trace.HeaderTraceparent))
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceId)
+	require.NotEqual(t, "00f067aa0ba902b7", parentId)
+	require.NotEqual(t, handlerTrace.SpanId, parentId) // span of outgoing call
+
+	// generated and logged on error
+	rep, err = http.Get(srv.URL + "/fail")
+	require.Nil(t, err)
+	_ = rep.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, rep.StatusCode)
+
+	requestId := rep.Header.Get(trace.HeaderRequestId)
+	require.Len(t, requestId, 32)
+
+	require.Len(t, lg.args, 1)
+	require.Contains(t, lg.args[0], "request_id")
+	require.Contains(t, lg.args[0], requestId)
+}
@@ -0,0 +1,18 @@
+package trace
+
+type traceCtxKeyT int8
+
+const (
+	HeaderRequestId   = "X-Request-Id"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+const (
+	traceCtxKey = traceCtxKeyT(1)
+
+	traceparentVersion = "00"
+	flagSampled        = "01"
+
+	requestIdMaxLen = 128
+)
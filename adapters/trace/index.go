@@ -0,0 +1,158 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/rendau/dop/adapters/logger"
+)
+
+func ContextWithTrace(ctx context.Context, t St) context.Context {
+	return context.WithValue(ctx, traceCtxKey, t)
+}
+
+func FromContext(ctx context.Context) (St, bool) {
+	if ctx == nil {
+		return St{}, false
+	}
+
+	t, ok := ctx.Value(traceCtxKey).(St)
+
+	return t, ok
+}
+
+// LogArgs - correlation args of context, nil if it has no trace
+func LogArgs(ctx context.Context) []any {
+	if t, ok := FromContext(ctx); ok {
+		return t.LogArgs()
+	}
+
+	return nil
+}
+
+// Logger - returns lg which adds correlation args of context to all calls
+func Logger(ctx context.Context, lg logger.Lite) logger.Lite {
+	args := LogArgs(ctx)
+	if len(args) == 0 {
+		return lg
+	}
+
+	return &LoggerSt{lg: lg, args: args}
+}
+
+// FromHeaders - accepts X-Request-Id and traceparent of incoming request or generates new ones,
+// SpanId is always new (span of the receiver)
+func FromHeaders(headers http.Header) St {
+	res := St{
+		RequestId: headers.Get(HeaderRequestId),
+		SpanId:    NewSpanId(),
+		Flags:     flagSampled,
+	}
+
+	if !validRequestId(res.RequestId) {
+		res.RequestId = NewRequestId()
+	}
+
+	if traceId, spanId, flags, ok := ParseTraceparent(headers.Get(HeaderTraceparent)); ok {
+		res.TraceId = traceId
+		res.ParentSpanId = spanId
+		res.Flags = flags
+		res.State = headers.Get(HeaderTracestate)
+	} else {
+		res.TraceId = NewTraceId()
+	}
+
+	return res
+}
+
+// SetHeaders - sets X-Request-Id, traceparent and tracestate of t, present headers are not changed
+func SetHeaders(headers http.Header, t St) {
+	if t.RequestId != "" && headers.Get(HeaderRequestId) == "" {
+		headers.Set(HeaderRequestId, t.RequestId)
+	}
+
+	if tp := t.Traceparent(); tp != "" && headers.Get(HeaderTraceparent) == "" {
+		headers.Set(HeaderTraceparent, tp)
+		if t.State != "" {
+			headers.Set(HeaderTracestate, t.State)
+		}
+	}
+}
+
+// ParseTraceparent - parses W3C traceparent header ("00-{trace-id}-{parent-id}-{flags}")
+func ParseTraceparent(v string) (traceId, spanId, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+
+	version := parts[0]
+	if !isLowerHex(version, 2) || version == "ff" || (version == traceparentVersion && len(parts) != 4) {
+		return "", "", "", false
+	}
+
+	traceId, spanId, flags = parts[1], parts[2], parts[3]
+
+	if !isLowerHex(traceId, 32) || isZeros(traceId) ||
+		!isLowerHex(spanId, 16) || isZeros(spanId) ||
+		!isLowerHex(flags, 2) {
+		return "", "", "", false
+	}
+
+	return traceId, spanId, flags, true
+}
+
+func NewRequestId() string {
+	return randomHex(16)
+}
+
+func NewTraceId() string {
+	return randomHex(16)
+}
+
+func NewSpanId() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func validRequestId(v string) bool {
+	if v == "" || len(v) > requestIdMaxLen {
+		return false
+	}
+
+	for i := 0; i < len(v); i++ {
+		if v[i] < 0x21 || v[i] > 0x7e { // printable ascii without spaces
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLowerHex(v string, size int) bool {
+	if len(v) != size {
+		return false
+	}
+
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isZeros(v string) bool {
+	return strings.Trim(v, "0") == ""
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceId, spanId, flags, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceId)
+	require.Equal(t, "00f067aa0ba902b7", spanId)
+	require.Equal(t, "01", flags)
+
+	// future version may have more fields
+	_, _, _, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	require.True(t, ok)
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		_, _, _, ok = ParseTraceparent(v)
+		require.False(t, ok, v)
+	}
+}
+
+func TestFromHeaders(t *testing.T) {
+	tr := FromHeaders(http.Header{
+		"X-Request-Id": {"req-1"},
+		"Traceparent":  {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		"Tracestate":   {"k=v"},
+	})
+	require.Equal(t, "req-1", tr.RequestId)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tr.TraceId)
+	require.Equal(t, "00f067aa0ba902b7", tr.ParentSpanId)
+	require.Len(t, tr.SpanId, 16)
+	require.NotEqual(t, tr.ParentSpanId, tr.SpanId)
+	require.Equal(t, "00", tr.Flags)
+	require.Equal(t, "k=v", tr.State)
+
+	// generated
+	tr = FromHeaders(http.Header{"X-Request-Id": {"bad id"}, "Traceparent": {"bad"}})
+	require.Len(t, tr.RequestId, 32)
+	require.Len(t, tr.TraceId, 32)
+	require.Empty(t, tr.ParentSpanId)
+	require.Empty(t, tr.State)
+
+	_, _, _, ok := ParseTraceparent(tr.Traceparent())
+	require.True(t, ok)
+}
+
+func TestPropagation(t *testing.T) {
+	ctx := context.Background()
+
+	_, ok := FromContext(ctx)
+	require.False(t, ok)
+	require.Nil(t, LogArgs(ctx))
+
+	tr := St{RequestId: "req-1", TraceId: NewTraceId(), SpanId: NewSpanId(), Flags: "01", State: "k=v"}
+
+	ctx = ContextWithTrace(ctx, tr)
+
+	got, ok := FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, tr, got)
+	require.Equal(t, []any{"request_id", "req-1", "trace_id", tr.TraceId, "span_id", tr.SpanId}, LogArgs(ctx))
+
+	child := tr.Child()
+	require.Equal(t, tr.TraceId, child.TraceId)
+	require.Equal(t, tr.SpanId, child.ParentSpanId)
+	require.NotEqual(t, tr.SpanId, child.SpanId)
+
+	headers := http.Header{}
+	SetHeaders(headers, child)
+	require.Equal(t, "req-1", headers.Get(HeaderRequestId))
+	require.Equal(t, "00-"+tr.TraceId+"-"+child.SpanId+"-01", headers.Get(HeaderTraceparent))
+	require.Equal(t, "k=v", headers.Get(HeaderTracestate))
+
+	// present headers are kept
+	headers = http.Header{HeaderRequestId: {"own"}}
+	SetHeaders(headers, child)
+	require.Equal(t, "own", headers.Get(HeaderRequestId))
+}
+
+type logRecSt struct {
+	msg  string
+	args []any
+}
+
+type testLoggerSt struct {
+	recs []logRecSt
+}
+
+func (l *testLoggerSt) Infow(msg string, args ...any) { l.recs = append(l.recs, logRecSt{msg, args}) }
+func (l *testLoggerSt) Warnw(msg string, args ...any) { l.recs = append(l.recs, logRecSt{msg, args}) }
+func (l *testLoggerSt) Errorw(msg string, err any, args ...any) {
+	l.recs = append(l.recs, logRecSt{msg, append([]any{"error", err}, args...)})
+}
+
+func TestLogger(t *testing.T) {
+	lg := &testLoggerSt{}
+
+	require.Same(t, lg, Logger(context.Background(), lg))
+
+	ctx := ContextWithTrace(context.Background(), St{RequestId: "req-1"})
+
+	tlg := Logger(ctx, lg)
+	tlg.Infow("info", "a", 1)
+	tlg.Errorw("error", "e")
+
+	require.Equal(t, []logRecSt{
+		{"info", []any{"a", 1, "request_id", "req-1"}},
+		{"error", []any{"error", "e", "request_id", "req-1"}},
+	}, lg.recs)
+}
@@ -0,0 +1,81 @@
+package trace
+
+import (
+	"github.com/rendau/dop/adapters/logger"
+)
+
+// St - correlation data of request
+type St struct {
+	RequestId string
+	// TraceId - 32 hex chars of W3C trace-context
+	TraceId string
+	// SpanId - 16 hex chars, id of current operation
+	SpanId string
+	// ParentSpanId - span of caller, empty for root
+	ParentSpanId string
+	// Flags - 2 hex chars of traceparent
+	Flags string
+	// State - tracestate header, propagated as is
+	State string
+}
+
+// LogArgs - key-value pairs for logger calls
+func (o St) LogArgs() []any {
+	res := make([]any, 0, 6)
+
+	if o.RequestId != "" {
+		res = append(res, "request_id", o.RequestId)
+	}
+
+	if o.TraceId != "" {
+		res = append(res, "trace_id", o.TraceId, "span_id", o.SpanId)
+	}
+
+	return res
+}
+
+// Traceparent - value of W3C traceparent header
+func (o St) Traceparent() string {
+	if o.TraceId == "" || o.SpanId == "" {
+		return ""
+	}
+
+	flags := o.Flags
+	if flags == "" {
+		flags = flagSampled
+	}
+
+	return traceparentVersion + "-" + o.TraceId + "-" + o.SpanId + "-" + flags
+}
+
+// Child - span of outgoing call within the same trace and request
+func (o St) Child() St {
+	res := o
+	res.ParentSpanId = o.SpanId
+	res.SpanId = NewSpanId()
+
+	if res.TraceId == "" {
+		res.TraceId = NewTraceId()
+		res.ParentSpanId = ""
+	}
+
+	return res
+}
+
+// LoggerSt - adds correlation args to all calls of wrapped logger
+type LoggerSt struct {
+	lg   logger.Lite
+	args []any
+}
+
+func (l *LoggerSt) Infow(msg string, args ...any) {
+	l.lg.Infow(msg, append(args, l.args...)...)
+}
+
+func (l *LoggerSt) Warnw(msg string, args ...any) {
+	l.lg.Warnw(msg, append(args, l.args...)...)
+}
+
+func (l *LoggerSt) Errorw(msg string, err any, args ...any) {
+	l.lg.Errorw(msg, err, append(args, l.args...)...)
+}
@@ -31,12 +31,14 @@ func (p *St) Send2Users(usrIds []int64, data any) error {
 		Message: data,
 	}
 
-	_, _, err := p.httpc.SendJson(reqObj, httpc.OptionsSt{
+	_, err := p.httpc.Send(&httpc.OptionsSt{
 		Method:        "POST",
-		Path:          "send",
+		Uri:           "send",
 		LogPrefix:     "Send: ",
 		RetryCount:    1,
 		RetryInterval: 3 * time.Second,
+
+		ReqObj: reqObj,
 	})
 	if err != nil {
 		return err
@@ -48,12 +50,14 @@ func (p *St) Send2Users(usrIds []int64, data any) error {
 func (p *St) GetConnectionCount() (int64, error) {
 	repObj := ws.ConnectionCountRepSt{}
 
-	_, _, err := p.httpc.SendRecvJson(nil, &repObj, nil, httpc.OptionsSt{
+	_, err := p.httpc.Send(&httpc.OptionsSt{
 		Method:        "POST",
-		Path:          "connection_count",
+		Uri:           "connection_count",
 		LogPrefix:     "ConnectionCount: ",
 		RetryCount:    1,
 		RetryInterval: time.Second,
+
+		RepObj: &repObj,
 	})
 	if err != nil {
 		return 0, err
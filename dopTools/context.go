@@ -0,0 +1,24 @@
+package dopTools
+
+import (
+	"context"
+	"time"
+)
+
+// DetachedContext - returns context with values of ctx, but without its deadline and cancellation.
+// Used for work shared by concurrent callers, which must not be canceled by the first of them
+func DetachedContext(ctx context.Context) context.Context {
+	return detachedCtxSt{parent: ctx}
+}
+
+type detachedCtxSt struct {
+	parent context.Context
+}
+
+func (detachedCtxSt) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedCtxSt) Done() <-chan struct{} { return nil }
+
+func (detachedCtxSt) Err() error { return nil }
+
+func (o detachedCtxSt) Value(key any) any { return o.parent.Value(key) }
@@ -0,0 +1,25 @@
+package dopTools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetachedContext(t *testing.T) {
+	type keyT struct{}
+
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), keyT{}, "v"), time.Minute)
+	cancel()
+
+	ctx := DetachedContext(parent)
+
+	require.Nil(t, ctx.Err())
+	require.Nil(t, ctx.Done())
+	require.Equal(t, "v", ctx.Value(keyT{}))
+
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+}
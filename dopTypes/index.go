@@ -28,6 +28,7 @@ type PaginatedListRep struct {
 	Page       int64 `json:"page"`
 	PageSize   int64 `json:"page_size"`
 	TotalCount int64 `json:"total_count"`
+	HasNext    bool  `json:"has_next"`
 
 	Results any `json:"results"`
 }
@@ -45,3 +46,8 @@ type ChartVByTimeSt struct {
 	Ts time.Time `json:"ts"`
 	V  int64     `json:"v"`
 }
+
+type ChartSeriesByTimeSt struct {
+	Key    string           `json:"key"`
+	Values []ChartVByTimeSt `json:"values"`
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/rendau/dop/adapters/db/pg"
@@ -26,13 +27,27 @@ func TestMain(m *testing.M) {
 	viper.AutomaticEnv()
 
 	viper.SetDefault("PG_DSN", "postgres://localhost/dop")
+	viper.SetDefault("PG_DRIVERS", pg.DriverPgxV4+","+pg.DriverPgxV5+","+pg.DriverSql)
 
-	app.db, err = pg.New(true, app.lg, pg.OptionsSt{
-		Dsn: viper.GetString("PG_DSN"),
-	})
-	if err != nil {
-		app.lg.Fatal(err)
+	for _, driver := range strings.Split(viper.GetString("PG_DRIVERS"), ",") {
+		app.lg.Infow("Run tests", "pg_driver", driver)
+
+		app.db, err = pg.New(true, app.lg, pg.OptionsSt{
+			Driver: driver,
+			Dsn:    viper.GetString("PG_DSN"),
+		})
+		if err != nil {
+			app.lg.Fatal(err)
+		}
+
+		code := m.Run()
+
+		app.db.Close()
+
+		if code != 0 {
+			os.Exit(code)
+		}
 	}
 
-	os.Exit(m.Run())
+	os.Exit(0)
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/rendau/dop/adapters/db"
+	"github.com/rendau/dop/dopErrs"
 	"github.com/rendau/dop/dopTools"
 	"github.com/rendau/dop/dopTypes"
 	"github.com/stretchr/testify/require"
@@ -209,6 +210,21 @@ func TestDbPgTx(t *testing.T) {
 		return app.db.DbQueryRow(ctx, `select count(*) from t1`).Scan(&c)
 	})
 	require.Nil(t, err)
+
+	// driver-neutral connection, joins transaction of context
+	err = app.db.TransactionFn(context.Background(), func(ctx context.Context) error {
+		err = app.db.Conn(ctx).Exec(ctx, `insert into t1 (c1) values ($1)`, "hello2")
+		if err != nil {
+			return err
+		}
+
+		return errors.New("test")
+	})
+	require.NotNil(t, err)
+
+	err = app.db.Conn(bgCtx).QueryRow(bgCtx, `select count(*) from t1`).Scan(&cnt)
+	require.Nil(t, err)
+	require.Equal(t, 1, cnt)
 }
 
 func TestDbPgTxAsyncCallback(t *testing.T) {
@@ -237,3 +253,269 @@ func TestDbPgTxAsyncCallback(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, 2, cnt)
 }
+
+func TestDbPgHfChartByTime(t *testing.T) {
+	err := app.db.DbExec(bgCtx, `drop table if exists t1 cascade`)
+	require.Nil(t, err)
+
+	err = app.db.DbExec(bgCtx, `
+		create table t1 (
+			ts timestamptz,
+			c1 text,
+			c2 int
+		);
+	`)
+	require.Nil(t, err)
+
+	err = app.db.DbExec(bgCtx, `
+		insert into t1 (ts, c1, c2) values
+			('2023-01-01T10:00:00Z', 'a', 1)
+			, ('2023-01-01T11:00:00Z', 'b', 2)
+			, ('2023-01-03T10:00:00Z', 'a', 3)
+	`)
+	require.Nil(t, err)
+
+	tsGte := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tsLte := time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	result, err := app.db.HfChartByTime(bgCtx, db.RDBChartByTimeOptions{
+		Tables:   []string{`t1`},
+		TsCol:    "ts",
+		Period:   dopTypes.PeriodPars{TsGTE: &tsGte, TsLTE: &tsLte},
+		Interval: db.ChartIntervalDay,
+		AggExpr:  "sum(c2)",
+		Timezone: "UTC",
+	})
+	require.Nil(t, err)
+	require.Len(t, result, 4)
+	require.Equal(t, []int64{3, 0, 3, 0}, []int64{result[0].V, result[1].V, result[2].V, result[3].V})
+	require.True(t, tsGte.Equal(result[0].Ts))
+
+	series, err := app.db.HfChartByTimeGrouped(bgCtx, db.RDBChartByTimeOptions{
+		Tables:    []string{`t1`},
+		TsCol:     "ts",
+		Period:    dopTypes.PeriodPars{TsGTE: &tsGte, TsLTE: &tsLte},
+		Interval:  db.ChartIntervalDay,
+		GroupExpr: "c1",
+		Timezone:  "UTC",
+	})
+	require.Nil(t, err)
+	require.Len(t, series, 2)
+	require.Equal(t, "a", series[0].Key)
+	require.Len(t, series[0].Values, 4)
+	require.Equal(t, int64(1), series[0].Values[2].V)
+	require.Equal(t, "b", series[1].Key)
+	require.Equal(t, int64(0), series[1].Values[2].V)
+
+	_, err = app.db.HfChartByTime(bgCtx, db.RDBChartByTimeOptions{
+		Tables:   []string{`t1`},
+		TsCol:    "ts",
+		Interval: "minute",
+	})
+	require.IsType(t, dopErrs.ErrWithDesc{}, err)
+	require.Equal(t, dopErrs.BadQueryParams, err.(dopErrs.ErrWithDesc).Err)
+
+	_, err = app.db.HfChartByTime(bgCtx, db.RDBChartByTimeOptions{
+		Tables:   []string{`t1`},
+		Interval: db.ChartIntervalDay,
+	})
+	require.IsType(t, dopErrs.ErrWithDesc{}, err)
+	require.Equal(t, dopErrs.BadQueryParams, err.(dopErrs.ErrWithDesc).Err)
+}
+
+func TestDbPgHfAudit(t *testing.T) {
+	err := app.db.DbExec(bgCtx, `drop table if exists t1, audit_log cascade`)
+	require.Nil(t, err)
+
+	err = app.db.DbExec(bgCtx, `
+		create table t1 (
+			id bigserial primary key,
+			c1 text,
+			c2 int
+		);
+		create table audit_log (
+			id bigserial primary key,
+			ts timestamptz not null,
+			tbl text not null,
+			entity_id text not null,
+			action text not null,
+			actor text not null,
+			old_values jsonb,
+			new_values jsonb
+		);
+	`)
+	require.Nil(t, err)
+
+	type T1CUSt struct {
+		C1 *string `db:"c1"`
+		C2 *int64  `db:"c2"`
+	}
+
+	ctx := db.ContextWithAuditActor(bgCtx, "usr-1")
+
+	var id int64
+
+	err = app.db.HfCreate(ctx, db.RDBCreateOptions{
+		Table:  `t1`,
+		Obj:    &T1CUSt{C1: dopTools.NewPtr("a"), C2: dopTools.NewPtr(int64(1))},
+		RetCol: "id",
+		RetV:   &id,
+		Audit:  true,
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(1), id)
+
+	err = app.db.HfUpdate(ctx, db.RDBUpdateOptions{
+		Table: `t1`,
+		Obj:   &T1CUSt{C1: dopTools.NewPtr("b"), C2: dopTools.NewPtr(int64(1))},
+		Conds: []string{`id = ${id}`},
+		Args:  map[string]any{"id": id},
+		Audit: true,
+	})
+	require.Nil(t, err)
+
+	err = app.db.HfDelete(ctx, db.RDBDeleteOptions{
+		Table: `t1`,
+		Conds: []string{`id = ${id}`},
+		Args:  map[string]any{"id": id},
+		Audit: true,
+	})
+	require.Nil(t, err)
+
+	rep, err := app.db.HfAuditList(bgCtx, db.RDBAuditListOptions{
+		Table:    `t1`,
+		EntityId: "1",
+		LPars:    dopTypes.ListParams{PageSize: 10},
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(3), rep.TotalCount)
+
+	records := rep.Results.([]*db.RDBAuditRecordSt)
+	require.Len(t, records, 3)
+
+	require.Equal(t, db.AuditActionDelete, records[0].Action)
+	require.Equal(t, "b", records[0].OldValues["c1"])
+	require.Nil(t, records[0].NewValues)
+
+	require.Equal(t, db.AuditActionUpdate, records[1].Action)
+	require.Equal(t, "usr-1", records[1].Actor)
+	require.Equal(t, map[string]any{"c1": "a"}, records[1].OldValues)
+	require.Equal(t, map[string]any{"c1": "b"}, records[1].NewValues)
+
+	require.Equal(t, db.AuditActionCreate, records[2].Action)
+	require.Nil(t, records[2].OldValues)
+	require.Equal(t, map[string]any{"c1": "a", "c2": float64(1)}, records[2].NewValues)
+}
+
+func TestDbPgHfAuditInTransaction(t *testing.T) {
+	err := app.db.DbExec(bgCtx, `drop table if exists t1, audit_log cascade`)
+	require.Nil(t, err)
+
+	err = app.db.DbExec(bgCtx, `
+		create table t1 (
+			id bigserial primary key,
+			c1 text
+		);
+		create table audit_log (
+			id bigserial primary key,
+			ts timestamptz not null,
+			tbl text not null,
+			entity_id text not null,
+			action text not null,
+			actor text not null,
+			old_values jsonb,
+			new_values jsonb
+		);
+	`)
+	require.Nil(t, err)
+
+	type T1CUSt struct {
+		C1 *string `db:"c1"`
+	}
+
+	count := func(table string) int64 {
+		var cnt int64
+		require.Nil(t, app.db.DbQueryRow(bgCtx, `select count(*) from `+table).Scan(&cnt))
+		return cnt
+	}
+
+	ctx := db.ContextWithAuditActor(bgCtx, "usr-1")
+
+	// audited write joins transaction of caller, so both are rolled back with it
+	err = app.db.TransactionFn(ctx, func(ctx context.Context) error {
+		err := app.db.HfCreate(ctx, db.RDBCreateOptions{
+			Table: `t1`,
+			Obj:   &T1CUSt{C1: dopTools.NewPtr("a")},
+			Audit: true,
+		})
+		require.Nil(t, err)
+
+		err = app.db.DbExec(ctx, `insert into t1 (c1) values ('b')`)
+		require.Nil(t, err)
+
+		return errors.New("rollback")
+	})
+	require.NotNil(t, err)
+	require.EqualValues(t, 0, count("t1"))
+	require.EqualValues(t, 0, count("audit_log"))
+
+	err = app.db.TransactionFn(ctx, func(ctx context.Context) error {
+		return app.db.HfCreate(ctx, db.RDBCreateOptions{
+			Table: `t1`,
+			Obj:   &T1CUSt{C1: dopTools.NewPtr("a")},
+			Audit: true,
+		})
+	})
+	require.Nil(t, err)
+	require.EqualValues(t, 1, count("t1"))
+	require.EqualValues(t, 1, count("audit_log"))
+}
+
+func TestDbPgHfListPaginated(t *testing.T) {
+	err := app.db.DbExec(bgCtx, `drop table if exists t1 cascade`)
+	require.Nil(t, err)
+
+	err = app.db.DbExec(bgCtx, `
+		create table t1 ( c1 int, c2 text );
+		insert into t1 (c1, c2) values (1, 'a'), (2, 'b'), (3, 'c');
+	`)
+	require.Nil(t, err)
+
+	type T1St struct {
+		C1 int64  `db:"c1"`
+		C2 string `db:"c2"`
+	}
+
+	newOps := func(lPars dopTypes.ListParams) db.RDBListRepOptions {
+		return db.RDBListRepOptions{
+			RDBListOptions: db.RDBListOptions{
+				Dst:          &[]*T1St{},
+				Tables:       []string{`t1`},
+				LPars:        lPars,
+				AllowedSorts: map[string]string{"default": "c1"},
+			},
+			MaxPageSize: 2,
+		}
+	}
+
+	rep, err := app.db.HfListPaginated(bgCtx, newOps(dopTypes.ListParams{PageSize: 2, WithTotalCount: true}))
+	require.Nil(t, err)
+	require.Equal(t, int64(3), rep.TotalCount)
+	require.True(t, rep.HasNext)
+	require.Len(t, rep.Results, 2)
+
+	rep, err = app.db.HfListPaginated(bgCtx, newOps(dopTypes.ListParams{Page: 1, PageSize: 2}))
+	require.Nil(t, err)
+	require.False(t, rep.HasNext)
+	require.Len(t, rep.Results, 1)
+
+	_, err = app.db.HfListPaginated(bgCtx, newOps(dopTypes.ListParams{PageSize: 3}))
+	require.Equal(t, dopErrs.IncorrectPageSize, err)
+
+	_, err = app.db.HfListPaginated(bgCtx, newOps(dopTypes.ListParams{PageSize: 2, Cols: []string{"c1", "c9"}}))
+	require.Equal(t, dopErrs.ErrWithDesc{Err: dopErrs.BadColumnName, Desc: "c9"}, err)
+
+	listRep, err := app.db.HfListRep(bgCtx, newOps(dopTypes.ListParams{Cols: []string{"c2"}}))
+	require.Nil(t, err)
+	require.Equal(t, []*T1St{{C2: "a"}, {C2: "b"}, {C2: "c"}}, listRep.Results)
+}